@@ -0,0 +1,330 @@
+// Command evo-bench measures shuffles and their proofs without the frontend.
+//
+//	evo-bench [-k 10,50,100] [-algorithms neff,sato] [-suites P256]
+//	          [-parallel false,true] [-csv results.csv] [-json results.json]
+//	          [-plot plot.png] [-tables=false] [-offline] [-mixers 3]
+//
+// Every combination of the swept parameters encrypts k ballots under a fresh
+// key, shuffles them, proves and verifies the shuffle. The timings are
+// written as CSV and JSON records and the total time per algorithm is plotted
+// against k. The plot format, PNG or SVG, follows the file extension.
+// Ballots are encrypted and Neff shuffles proven with fixed-base tables of
+// the election key unless -tables=false, building them is not timed. With
+// -offline every Neff shuffle is prepared ahead of the ballots, the offline
+// phase is recorded separately and not part of the total time. With -mixers n
+// the Neff shuffles run as a cascade of n mix servers, the timings of every
+// hop are recorded next to the totals.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/mixnet"
+	"github.com/qantik/evo/backend/crypto/sato"
+	"github.com/qantik/evo/backend/crypto/suites"
+	"github.com/qantik/evo/backend/crypto/transcript"
+)
+
+// Timings of a single run in seconds, summed over the hops.
+type result struct {
+	Algorithm string  `json:"algorithm"`
+	Suite     string  `json:"suite"`
+	Votes     int     `json:"votes"`
+	Parallel  bool    `json:"parallel"`
+	Mixers    int     `json:"mixers"`
+	Offline   float64 `json:"offline"`
+	Shuffle   float64 `json:"shuffle"`
+	Prove     float64 `json:"prove"`
+	Simple    float64 `json:"simple"`
+	Verify    float64 `json:"verify"`
+	Time      float64 `json:"time"`
+	Hops      []hop   `json:"hops"`
+}
+
+// Timings of a single mix server in seconds.
+type hop struct {
+	Offline float64 `json:"offline"`
+	Shuffle float64 `json:"shuffle"`
+	Prove   float64 `json:"prove"`
+	Simple  float64 `json:"simple"`
+}
+
+func newHop(t mixnet.Timings) hop {
+	return hop{Offline: t.Offline.Seconds(), Shuffle: t.Shuffle.Seconds(),
+		Prove: t.Prove.Seconds(), Simple: t.Simple.Seconds()}
+}
+
+func main() {
+	ks := flag.String("k", "10,50,100,200,300,400,500", "numbers of ballots")
+	algorithms := flag.String("algorithms", "neff,sato", "shuffle proofs, neff or sato")
+	names := flag.String("suites", suites.Default,
+		"suites, any of "+strings.Join(suites.Names(), ", "))
+	parallel := flag.String("parallel", "false,true", "prove and verify sequentially, in parallel or both")
+	rounds := flag.Int("rounds", sato.DefaultRounds, "Sako-Kilian rounds")
+	csvPath := flag.String("csv", "", "output file for CSV results")
+	jsonPath := flag.String("json", "", "output file for JSON results")
+	plotPath := flag.String("plot", "", "output file for the plot, .png or .svg")
+	seed := flag.String("seed", "", "derive all randomness from this seed")
+	tables := flag.Bool("tables", true, "use fixed-base tables of the election key")
+	offline := flag.Bool("offline", false, "prepare Neff shuffles ahead of the ballots")
+	mixers := flag.Int("mixers", 1, "mix servers of the Neff cascade")
+	flag.Parse()
+
+	if *mixers < 1 {
+		fail(fmt.Errorf("-mixers %d: at least one mix server", *mixers))
+	}
+
+	votes, err := ints(*ks)
+	if err != nil {
+		fail(err)
+	}
+	modes, err := bools(*parallel)
+	if err != nil {
+		fail(err)
+	}
+
+	var results []result
+	for _, name := range split(*names) {
+		suite, err := suites.Lookup(name)
+		if err != nil {
+			fail(err)
+		}
+		if *seed != "" {
+			suite = suites.Seeded(suite, []byte(*seed))
+		}
+
+		for _, algorithm := range split(*algorithms) {
+			for _, p := range modes {
+				for _, k := range votes {
+					res, err := run(suite, algorithm, k, p, *rounds, *tables, *offline,
+						*mixers)
+					if err != nil {
+						fail(fmt.Errorf("%s %s k=%d: %v", algorithm, name, k, err))
+					}
+					fmt.Fprintf(os.Stderr, "%s %s k=%d parallel=%t time=%.3fs\n",
+						algorithm, name, k, p, res.Time)
+					results = append(results, res)
+				}
+			}
+		}
+	}
+
+	if *csvPath != "" {
+		if err := writeCSV(*csvPath, results); err != nil {
+			fail(err)
+		}
+	}
+	if *jsonPath != "" {
+		if err := writeJSON(*jsonPath, results); err != nil {
+			fail(err)
+		}
+	}
+	if *plotPath != "" {
+		if err := plot(*plotPath, results); err != nil {
+			fail(err)
+		}
+	}
+}
+
+// Encrypt k ballots, shuffle them, prove and verify the shuffle. Neff
+// shuffles run through a cascade of the given number of mix servers.
+func run(suite suites.Suite, algorithm string, k int, parallel bool, rounds int,
+	tables, offline bool, mixers int) (result, error) {
+
+	res := result{Algorithm: algorithm, Suite: suite.String(), Votes: k,
+		Parallel: parallel, Mixers: 1}
+
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+	g := suite.Point().Base()
+	var bases *elgamal.Bases
+	if tables {
+		bases = elgamal.NewBases(suite, g, key.Public)
+	}
+	A := make([]kyber.Point, k)
+	B := make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		vote := []byte(fmt.Sprintf("vote#%d", i))
+		var err error
+		if bases != nil {
+			A[i], B[i], err = bases.Encrypt(vote, stream)
+		} else {
+			A[i], B[i], err = elgamal.Encrypt(suite, key.Public, vote, stream)
+		}
+		if err != nil {
+			return res, err
+		}
+	}
+
+	session := []byte("evo-bench")
+	var t mixnet.Timings
+	var verify time.Duration
+	switch algorithm {
+	case "neff":
+		cascade := mixnet.New(suite, g, key.Public, A, B).SetSession(session).
+			SetBases(bases).SetOffline(offline)
+		if parallel {
+			cascade.SetWorkers(runtime.NumCPU())
+		}
+		for i := 0; i < mixers; i++ {
+			if err := cascade.Mix(stream); err != nil {
+				return res, err
+			}
+			res.Hops = append(res.Hops, newHop(cascade.Hops[i].Timings))
+		}
+		res.Mixers = mixers
+		t = cascade.Timings()
+
+		start := time.Now()
+		if err := cascade.Verify(); err != nil {
+			return res, err
+		}
+		verify = time.Since(start)
+	case "sato":
+		sp := &transcript.ShuffleProof{Suite: suite, Protocol: transcript.SakoKilian,
+			Rounds: rounds, Session: session, G: g, H: key.Public, X: A, Y: B}
+
+		start := time.Now()
+		var prover proof.Prover
+		var err error
+		sp.Xbar, sp.Ybar, prover, err = sato.Shuffle(suite, g, key.Public, A, B, rounds, parallel, stream)
+		t.Shuffle = time.Since(start)
+		if err != nil {
+			return res, err
+		}
+
+		start = time.Now()
+		err = sp.Prove(prover)
+		t.Prove = time.Since(start)
+		if err != nil {
+			return res, err
+		}
+
+		start = time.Now()
+		verifier, err := sato.Verifier(suite, g, key.Public, A, B, sp.Xbar, sp.Ybar,
+			rounds, parallel)
+		if err == nil {
+			var name string
+			if name, err = sp.Domain(); err == nil {
+				err = proof.HashVerify(suite, name, verifier, sp.Proof)
+			}
+		}
+		verify = time.Since(start)
+		if err != nil {
+			return res, err
+		}
+		res.Hops = []hop{newHop(t)}
+	default:
+		return res, fmt.Errorf("unknown algorithm %s", algorithm)
+	}
+
+	res.Offline = t.Offline.Seconds()
+	res.Shuffle = t.Shuffle.Seconds()
+	res.Prove = t.Prove.Seconds()
+	res.Simple = t.Simple.Seconds()
+	res.Verify = verify.Seconds()
+	res.Time = res.Shuffle + res.Prove + res.Verify
+	return res, nil
+}
+
+func writeCSV(path string, results []result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// A row with the totals of every run followed by one per hop, which
+	// leaves the verification and the total time empty.
+	w := csv.NewWriter(f)
+	w.Write([]string{"algorithm", "suite", "votes", "parallel", "mixers", "hop",
+		"offline", "shuffle", "prove", "simple", "verify", "time"})
+	for _, r := range results {
+		run := []string{r.Algorithm, r.Suite, strconv.Itoa(r.Votes),
+			strconv.FormatBool(r.Parallel), strconv.Itoa(r.Mixers)}
+		w.Write(append(run[:5:5], "all", seconds(r.Offline), seconds(r.Shuffle),
+			seconds(r.Prove), seconds(r.Simple), seconds(r.Verify), seconds(r.Time)))
+		for i, h := range r.Hops {
+			w.Write(append(run[:5:5], strconv.Itoa(i), seconds(h.Offline),
+				seconds(h.Shuffle), seconds(h.Prove), seconds(h.Simple), "", ""))
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeJSON(path string, results []result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func seconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 6, 64)
+}
+
+// Split a comma separated flag value.
+func split(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func ints(s string) ([]int, error) {
+	var n []int
+	for _, f := range split(s) {
+		i, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, i)
+	}
+	return n, nil
+}
+
+func bools(s string) ([]bool, error) {
+	var b []bool
+	for _, f := range split(s) {
+		v, err := strconv.ParseBool(f)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, v)
+	}
+	return b, nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	gonum "gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+var labels = map[string]string{
+	"neff": "Neff",
+	"sato": "Sako-Kilian",
+}
+
+// Plot the total time of every algorithm, suite and parallelism against the
+// number of ballots on a logarithmic scale.
+func plot(path string, results []result) error {
+	p := gonum.New()
+	p.Title.Text = "ElGamal Pairs Shuffle Verification Benchmarks"
+	p.X.Label.Text = "Number of Encrypted Votes"
+	p.Y.Label.Text = "Time [s]"
+	p.Y.Scale = gonum.LogScale{}
+	p.Y.Tick.Marker = gonum.LogTicks{Prec: -1}
+	p.Legend.Top = true
+	p.Legend.Left = true
+	p.Add(plotter.NewGrid())
+
+	multiple := false
+	for _, r := range results {
+		multiple = multiple || r.Suite != results[0].Suite
+	}
+
+	var order []string
+	series := make(map[string]plotter.XYs)
+	for _, r := range results {
+		name := labels[r.Algorithm]
+		if r.Parallel {
+			name += " (Parallel)"
+		}
+		if multiple {
+			name += " " + r.Suite
+		}
+		if _, ok := series[name]; !ok {
+			order = append(order, name)
+		}
+		series[name] = append(series[name], plotter.XY{X: float64(r.Votes), Y: r.Time})
+	}
+
+	var lines []interface{}
+	for _, name := range order {
+		lines = append(lines, name, series[name])
+	}
+	if err := plotutil.AddLinePoints(p, lines...); err != nil {
+		return fmt.Errorf("plot: %v", err)
+	}
+
+	return p.Save(8*vg.Inch, 6*vg.Inch, path)
+}
@@ -0,0 +1,61 @@
+// Command evo-keygen generates an election key pair and writes it to disk
+// in the format documented in the elgamal package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func main() {
+	secret := flag.String("secret", "election.key", "output file for the key pair")
+	public := flag.String("public", "election.pub", "output file for the public key")
+	name := flag.String("suite", suites.Default,
+		"suite of the key, one of "+strings.Join(suites.Names(), ", "))
+	flag.Parse()
+
+	suite, err := suites.Lookup(*name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	key := elgamal.GenerateKey(suite, suite.RandomStream())
+
+	if err := write(suite, key, *secret, true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := write(suite, key, *public, false); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func write(suite suites.Suite, key *elgamal.KeyPair, path string, secret bool) error {
+	perm := os.FileMode(0644)
+	if secret {
+		perm = 0600
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if secret {
+		err = key.Write(suite, f)
+	} else {
+		err = key.WritePublic(suite, f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
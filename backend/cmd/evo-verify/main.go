@@ -0,0 +1,100 @@
+// Command evo-verify checks published shuffle proof transcripts offline.
+//
+//	evo-verify [-chain=false] [-batch=false] [-legacy] transcript...
+//
+// Every file holds a single binary or JSON transcript or a JSON array of
+// transcripts. The hops of all files are verified in the given order and, if
+// chain is set, every hop has to shuffle the output of the previous one of
+// the same session. The Neff proofs of the same suite are batch verified
+// unless batch is unset. A report line is printed per hop and the exit code
+// is 1 if any check fails.
+//
+// Transcripts of versions 1 and 2, whose Fiat-Shamir challenges do not depend
+// on the shuffle, fail unless legacy is set. Even then their proofs do not
+// show that this particular shuffle was proven, they are reported as WEAK and
+// the exit code is 3 if no check failed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/qantik/evo/backend/crypto/transcript"
+)
+
+type hop struct {
+	file  string
+	index int
+	proof *transcript.ShuffleProof
+}
+
+func main() {
+	chain := flag.Bool("chain", true, "require consecutive hops to be linked")
+	batch := flag.Bool("batch", true, "batch verify the Neff proofs")
+	legacy := flag.Bool("legacy", false, "accept transcripts not bound to their statement")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: evo-verify [-chain=false] [-batch=false] [-legacy] transcript...")
+		os.Exit(2)
+	}
+
+	var hops []hop
+	for _, path := range flag.Args() {
+		sps, err := transcript.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(1)
+		}
+		for i, sp := range sps {
+			hops = append(hops, hop{path, i, sp})
+		}
+	}
+
+	errs := make([]error, len(hops))
+	if *batch {
+		sps := make([]*transcript.ShuffleProof, len(hops))
+		for i, h := range hops {
+			sps[i] = h.proof
+		}
+		errs = transcript.VerifyAll(sps, *legacy)
+	} else {
+		for i, h := range hops {
+			if *legacy {
+				errs[i] = h.proof.VerifyLegacy()
+			} else {
+				errs[i] = h.proof.Verify()
+			}
+		}
+	}
+
+	failed, weak := false, false
+	for i, h := range hops {
+		status := "PASS"
+		err := errs[i]
+		if err == nil && *chain && i > 0 {
+			if err = transcript.Link(hops[i-1].proof, h.proof); err != nil {
+				err = fmt.Errorf("not linked to hop %d: %v", i-1, err)
+			}
+		}
+		if err != nil {
+			status = "FAIL: " + err.Error()
+			failed = true
+		} else if h.proof.Legacy() {
+			status = "WEAK: proof not bound to the statement"
+			weak = true
+		}
+
+		fmt.Printf("hop %d\t%s[%d]\t%s\t%s\tk=%d\twidth=%d\t%s\n", i, h.file,
+			h.index, h.proof.Suite.String(), h.proof.Protocol, h.proof.Rows(),
+			h.proof.Width, status)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+	if weak {
+		os.Exit(3)
+	}
+}
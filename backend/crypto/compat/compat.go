@@ -0,0 +1,99 @@
+/*
+Package compat lets proofs and ballots of the former gopkg.in/dedis/crypto.v0
+based implementation interoperate with the kyber based packages. Groups and
+encodings of both libraries agree, only the Fiat-Shamir hash differs: the old
+proofs were bound to a SHAKE sponge cipher while kyber suites use their XOF.
+*/
+package compat
+
+import (
+	"errors"
+
+	"go.dedis.ch/kyber/v3"
+	"gopkg.in/dedis/crypto.v0/abstract"
+	"gopkg.in/dedis/crypto.v0/cipher/sha3"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+// Sponge ciphers the crypto.v0 suites derived their public randomness from.
+var ciphers = map[string]func(key []byte, options ...interface{}) abstract.Cipher{
+	"P256":        sha3.NewShakeCipher128,
+	"P384":        sha3.NewShakeCipher256,
+	"P521":        sha3.NewShakeCipher256,
+	"Ed25519":     sha3.NewShakeCipher128,
+	"Residue512":  sha3.NewShakeCipher128,
+	"Residue2048": sha3.NewShakeCipher128,
+}
+
+// XOF driving a crypto.v0 cipher the way the old hash prover did: every
+// message is absorbed as a complete cipher message and challenges are read
+// from the key stream without reseeding.
+type xof struct {
+	c abstract.Cipher
+}
+
+func (x *xof) Write(buf []byte) (int, error) {
+	x.c.Message(nil, nil, buf)
+	return len(buf), nil
+}
+
+func (x *xof) Read(buf []byte) (int, error) {
+	return x.c.Read(buf)
+}
+
+func (x *xof) XORKeyStream(dst, src []byte) {
+	x.c.XORKeyStream(dst, src)
+}
+
+func (x *xof) Reseed() {}
+
+func (x *xof) Clone() kyber.XOF {
+	return &xof{x.c.Clone()}
+}
+
+type legacySuite struct {
+	suites.Suite
+	cipher func(key []byte, options ...interface{}) abstract.Cipher
+}
+
+func (s *legacySuite) XOF(seed []byte) kyber.XOF {
+	return &xof{s.cipher(seed)}
+}
+
+// Return a view of suite whose Fiat-Shamir hash matches the one of the
+// crypto.v0 suite of the same name, so that proofs made by the former
+// implementation verify under the kyber based verifiers.
+func Legacy(suite suites.Suite) (suites.Suite, error) {
+	cipher, ok := ciphers[suite.String()]
+	if !ok {
+		return nil, errors.New("no legacy cipher for suite " + suite.String())
+	}
+	return &legacySuite{suite, cipher}, nil
+}
+
+// Convert a crypto.v0 point into a point of group through their common
+// binary encoding.
+func Point(group kyber.Group, p abstract.Point) (kyber.Point, error) {
+	buf, err := p.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	q := group.Point()
+	if err := q.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Convert a vector of crypto.v0 points.
+func Points(group kyber.Group, P []abstract.Point) ([]kyber.Point, error) {
+	Q := make([]kyber.Point, len(P))
+	for i := range P {
+		var err error
+		if Q[i], err = Point(group, P[i]); err != nil {
+			return nil, err
+		}
+	}
+	return Q, nil
+}
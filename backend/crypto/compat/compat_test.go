@@ -0,0 +1,148 @@
+package compat_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	kproof "go.dedis.ch/kyber/v3/proof"
+	"gopkg.in/dedis/crypto.v0/abstract"
+	"gopkg.in/dedis/crypto.v0/ed25519"
+	"gopkg.in/dedis/crypto.v0/nist"
+	"gopkg.in/dedis/crypto.v0/proof"
+	"gopkg.in/dedis/crypto.v0/shuffle"
+
+	"github.com/qantik/evo/backend/crypto/compat"
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/neff"
+	"github.com/qantik/evo/backend/crypto/suites"
+	"github.com/qantik/evo/backend/crypto/transcript"
+)
+
+// Run the crypto.v0 Neff shuffle with a seeded cipher and check its proof
+// under the kyber based verifier.
+func TestUpstreamNeff(t *testing.T) {
+	for _, old := range []abstract.Suite{
+		nist.NewAES128SHA256P256(),
+		ed25519.NewAES128SHA256Ed25519(false),
+		nist.NewAES128SHA256QR512(),
+	} {
+		t.Run(old.String(), func(t *testing.T) {
+			stream := old.Cipher([]byte("evo compat"))
+			secret := old.Scalar().Pick(stream)
+			h := old.Point().Mul(nil, secret)
+			g := old.Point().Base()
+
+			X := make([]abstract.Point, 5)
+			Y := make([]abstract.Point, 5)
+			for i := range X {
+				m, _ := old.Point().Pick([]byte(fmt.Sprintf("vote#%d", i)), stream)
+				r := old.Scalar().Pick(stream)
+				X[i] = old.Point().Mul(nil, r)
+				Y[i] = old.Point().Mul(h, r)
+				Y[i].Add(Y[i], m)
+			}
+			Xbar, Ybar, prover := shuffle.Shuffle(old, g, h, X, Y, stream)
+			stamp, err := proof.HashProve(old, "PS", stream, prover)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			suite, err := suites.Lookup(old.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			legacy, err := compat.Legacy(suite)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			G, err := compat.Points(suite, []abstract.Point{g, h})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var V [4][]kyber.Point
+			for i, P := range [][]abstract.Point{X, Y, Xbar, Ybar} {
+				if V[i], err = compat.Points(suite, P); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			verifier, err := neff.Verifier(suite, G[0], G[1], V[0], V[1], V[2], V[3], 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := kproof.HashVerify(legacy, "PS", verifier, stamp); err != nil {
+				t.Fatal(err)
+			}
+			if kproof.HashVerify(suite, "PS", verifier, stamp) == nil {
+				t.Fatal("legacy proof verified under the kyber hash")
+			}
+		})
+	}
+}
+
+// Vectors produced by the crypto.v0 implementation of this repository with
+// ciphers seeded by "evo legacy vectors".
+func TestLegacyTranscripts(t *testing.T) {
+	for _, file := range []string{"neff-P256.json", "sako-kilian-Ed25519.bin"} {
+		t.Run(file, func(t *testing.T) {
+			sps, err := transcript.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !transcript.Chained(sps) {
+				t.Fatal("transcripts not chained")
+			}
+			for _, sp := range sps {
+				if sp.Version != 1 {
+					t.Fatal("transcript not marked as legacy")
+				}
+				if err := sp.Verify(); err != transcript.ErrLegacy {
+					t.Fatalf("legacy transcript accepted by default: %v", err)
+				}
+				if err := sp.VerifyLegacy(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// The ballots embedded by the old suites still decrypt.
+			last := sps[len(sps)-1]
+			f, err := os.Open(filepath.Join("testdata", last.Suite.String()+".key"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			key, err := elgamal.ReadKey(last.Suite, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			votes := make(map[string]bool)
+			for i := range last.Xbar {
+				m, err := elgamal.Decrypt(last.Suite, key.Secret, last.Xbar[i], last.Ybar[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				votes[string(m)] = true
+			}
+			for i := range last.Xbar {
+				if !votes[fmt.Sprintf("vote#%d", i)] {
+					t.Fatalf("vote#%d lost", i)
+				}
+			}
+
+			// The proofs are bound to the legacy Fiat-Shamir hash.
+			last.Version = 2
+			if last.VerifyLegacy() == nil {
+				t.Fatal("legacy proof verified under the kyber hash")
+			}
+			last.Version = 1
+			last.Xbar[0], last.Xbar[1] = last.Xbar[1], last.Xbar[0]
+			if last.VerifyLegacy() == nil {
+				t.Fatal("tampered legacy transcript verified")
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package dkg
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sort"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+)
+
+// Broadcast commitments g^a_k to the coefficients of a dealer's polynomial.
+type Deal struct {
+	Dealer      int
+	Commitments []kyber.Point
+}
+
+// Private evaluation f(Receiver) of the dealer's polynomial.
+type Share struct {
+	Dealer   int
+	Receiver int
+	Value    kyber.Scalar
+}
+
+// Broadcast accusation of a dealer for having sent an invalid or no share.
+type Complaint struct {
+	Dealer     int
+	Complainer int
+}
+
+// Broadcast answer of a dealer to a complaint publishing the disputed share.
+type Justification struct {
+	Dealer     int
+	Complainer int
+	Value      kyber.Scalar
+}
+
+// Outcome of a successful key generation for a single trustee. Share is the
+// trustee's secret share x_i of the election key, Verification[i-1] is the
+// public g^x_i of trustee i used to check its partial decryptions.
+type DistKey struct {
+	Index        int
+	Threshold    int
+	Share        kyber.Scalar
+	Public       kyber.Point
+	Verification []kyber.Point
+	Qualified    []int
+}
+
+// Simulated trustee taking part in the key generation. Trustees are indexed
+// from 1 to n, the index is also the evaluation point of its shares.
+type Trustee struct {
+	suite  kyber.Group
+	index  int
+	n, t   int
+	poly   []kyber.Scalar
+	deals  map[int]*Deal
+	shares map[int]kyber.Scalar
+
+	// pending[dealer][complainer] is set for unanswered complaints.
+	pending      map[int]map[int]bool
+	disqualified map[int]bool
+}
+
+// Create trustee index out of n with reconstruction threshold t.
+func NewTrustee(suite kyber.Group, index, n, t int, stream cipher.Stream) (
+	*Trustee, error) {
+
+	if t < 1 || t > n {
+		return nil, errors.New("threshold out of range")
+	}
+	if index < 1 || index > n {
+		return nil, errors.New("trustee index out of range")
+	}
+
+	tr := &Trustee{
+		suite:        suite,
+		index:        index,
+		n:            n,
+		t:            t,
+		poly:         make([]kyber.Scalar, t),
+		deals:        make(map[int]*Deal),
+		shares:       make(map[int]kyber.Scalar),
+		pending:      make(map[int]map[int]bool),
+		disqualified: make(map[int]bool),
+	}
+	for k := 0; k < t; k++ {
+		tr.poly[k] = suite.Scalar().Pick(stream)
+	}
+
+	return tr, nil
+}
+
+func (tr *Trustee) Index() int {
+	return tr.index
+}
+
+// Evaluate the secret polynomial at x using Horner's rule.
+func (tr *Trustee) eval(x int) kyber.Scalar {
+	xs := tr.suite.Scalar().SetInt64(int64(x))
+	v := tr.suite.Scalar().Set(tr.poly[tr.t-1])
+	for k := tr.t - 2; k >= 0; k-- {
+		v.Mul(v, xs)
+		v.Add(v, tr.poly[k])
+	}
+	return v
+}
+
+// Evaluate committed polynomial at x in the exponent.
+func evalCommitments(suite kyber.Group, C []kyber.Point, x int) kyber.Point {
+	xs := suite.Scalar().SetInt64(int64(x))
+	P := suite.Point().Set(C[len(C)-1])
+	for k := len(C) - 2; k >= 0; k-- {
+		P.Mul(xs, P)
+		P.Add(P, C[k])
+	}
+	return P
+}
+
+// Check a share value against the dealer's commitments.
+func (tr *Trustee) valid(dealer, receiver int, value kyber.Scalar) bool {
+	deal, ok := tr.deals[dealer]
+	if !ok || value == nil {
+		return false
+	}
+	P := tr.suite.Point().Mul(value, nil)
+	return P.Equal(evalCommitments(tr.suite, deal.Commitments, receiver))
+}
+
+// Produce the broadcast deal and the private shares for all n trustees,
+// including the dealer itself.
+func (tr *Trustee) Deal() (*Deal, []*Share) {
+	deal := &Deal{Dealer: tr.index, Commitments: make([]kyber.Point, tr.t)}
+	for k := 0; k < tr.t; k++ {
+		deal.Commitments[k] = tr.suite.Point().Mul(tr.poly[k], nil)
+	}
+
+	shares := make([]*Share, tr.n)
+	for j := 1; j <= tr.n; j++ {
+		shares[j-1] = &Share{Dealer: tr.index, Receiver: j, Value: tr.eval(j)}
+	}
+
+	return deal, shares
+}
+
+// Record a broadcast deal. Malformed deals disqualify the dealer.
+func (tr *Trustee) ProcessDeal(deal *Deal) error {
+	if deal.Dealer < 1 || deal.Dealer > tr.n {
+		return errors.New("dealer index out of range")
+	}
+	if _, ok := tr.deals[deal.Dealer]; ok {
+		return errors.New("duplicate deal")
+	}
+	if len(deal.Commitments) != tr.t {
+		tr.disqualified[deal.Dealer] = true
+		return errors.New("deal has wrong number of commitments")
+	}
+
+	tr.deals[deal.Dealer] = deal
+	return nil
+}
+
+// Record a private share sent to this trustee. A complaint is returned for
+// broadcasting if the share does not match the dealer's commitments.
+func (tr *Trustee) ProcessShare(share *Share) (*Complaint, error) {
+	if share.Receiver != tr.index {
+		return nil, errors.New("share is addressed to another trustee")
+	}
+	if _, ok := tr.deals[share.Dealer]; !ok {
+		return nil, errors.New("share received before deal")
+	}
+
+	if !tr.valid(share.Dealer, tr.index, share.Value) {
+		return &Complaint{Dealer: share.Dealer, Complainer: tr.index}, nil
+	}
+
+	tr.shares[share.Dealer] = share.Value
+	return nil, nil
+}
+
+// Complaints against dealers from which no valid share has been received,
+// including those whose share was rejected by ProcessShare.
+func (tr *Trustee) Missing() []*Complaint {
+	var complaints []*Complaint
+	for dealer := 1; dealer <= tr.n; dealer++ {
+		if _, ok := tr.shares[dealer]; !ok && !tr.disqualified[dealer] {
+			complaints = append(complaints,
+				&Complaint{Dealer: dealer, Complainer: tr.index})
+		}
+	}
+	return complaints
+}
+
+// Record a broadcast complaint. The complaint stays pending until the
+// dealer publishes a valid justification.
+func (tr *Trustee) ProcessComplaint(c *Complaint) error {
+	if c.Dealer < 1 || c.Dealer > tr.n || c.Complainer < 1 || c.Complainer > tr.n {
+		return errors.New("complaint index out of range")
+	}
+
+	if tr.pending[c.Dealer] == nil {
+		tr.pending[c.Dealer] = make(map[int]bool)
+	}
+	tr.pending[c.Dealer][c.Complainer] = true
+	return nil
+}
+
+// Answer a complaint against this trustee by revealing the disputed share.
+func (tr *Trustee) Justify(c *Complaint) (*Justification, error) {
+	if c.Dealer != tr.index {
+		return nil, errors.New("complaint is against another dealer")
+	}
+
+	return &Justification{
+		Dealer:     tr.index,
+		Complainer: c.Complainer,
+		Value:      tr.eval(c.Complainer),
+	}, nil
+}
+
+// Record a broadcast justification. An invalid justification disqualifies
+// the dealer, a valid one resolves the complaint and hands the complainer
+// its share.
+func (tr *Trustee) ProcessJustification(j *Justification) error {
+	if !tr.pending[j.Dealer][j.Complainer] {
+		return errors.New("justification without complaint")
+	}
+
+	if !tr.valid(j.Dealer, j.Complainer, j.Value) {
+		tr.disqualified[j.Dealer] = true
+		return nil
+	}
+
+	delete(tr.pending[j.Dealer], j.Complainer)
+	if j.Complainer == tr.index {
+		tr.shares[j.Dealer] = j.Value
+	}
+	return nil
+}
+
+// Qualified dealers in ascending order: those that published a deal and
+// answered every complaint against them.
+func (tr *Trustee) Qualified() []int {
+	var qual []int
+	for dealer := range tr.deals {
+		if !tr.disqualified[dealer] && len(tr.pending[dealer]) == 0 {
+			qual = append(qual, dealer)
+		}
+	}
+	sort.Ints(qual)
+	return qual
+}
+
+// Conclude the key generation and compute the trustee's share of the
+// election key from the shares of all qualified dealers.
+func (tr *Trustee) Finalize() (*DistKey, error) {
+	qual := tr.Qualified()
+	if len(qual) < tr.t {
+		return nil, errors.New("fewer than t qualified dealers")
+	}
+
+	key := &DistKey{
+		Index:        tr.index,
+		Threshold:    tr.t,
+		Share:        tr.suite.Scalar().Zero(),
+		Public:       tr.suite.Point().Null(),
+		Verification: make([]kyber.Point, tr.n),
+		Qualified:    qual,
+	}
+	for j := 0; j < tr.n; j++ {
+		key.Verification[j] = tr.suite.Point().Null()
+	}
+
+	for _, dealer := range qual {
+		share, ok := tr.shares[dealer]
+		if !ok {
+			return nil, errors.New("missing share of qualified dealer")
+		}
+		key.Share.Add(key.Share, share)
+
+		C := tr.deals[dealer].Commitments
+		key.Public.Add(key.Public, C[0])
+		for j := 1; j <= tr.n; j++ {
+			key.Verification[j-1].Add(key.Verification[j-1],
+				evalCommitments(tr.suite, C, j))
+		}
+	}
+
+	return key, nil
+}
+
+// Election key pair holding only the jointly generated public key.
+func (key *DistKey) KeyPair() *elgamal.KeyPair {
+	return &elgamal.KeyPair{Public: key.Public}
+}
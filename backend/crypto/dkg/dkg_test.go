@@ -0,0 +1,196 @@
+package dkg
+
+import (
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/nist"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+// Run the key generation among in-process trustees. Dealers listed in
+// corrupt send an invalid share to the last trustee, those listed in silent
+// additionally refuse to answer the resulting complaint.
+func simulate(t *testing.T, suite kyber.Group, n, th int,
+	corrupt, silent map[int]bool) []*DistKey {
+
+	stream := random.New()
+	trustees := make([]*Trustee, n)
+	for i := range trustees {
+		tr, err := NewTrustee(suite, i+1, n, th, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trustees[i] = tr
+	}
+
+	deals := make([]*Deal, n)
+	shares := make([][]*Share, n)
+	for i, tr := range trustees {
+		deals[i], shares[i] = tr.Deal()
+		if corrupt[i+1] {
+			shares[i][n-1].Value = suite.Scalar().Pick(stream)
+		}
+	}
+
+	for _, tr := range trustees {
+		for _, deal := range deals {
+			if err := tr.ProcessDeal(deal); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var complaints []*Complaint
+	for i := range trustees {
+		for j, tr := range trustees {
+			c, err := tr.ProcessShare(shares[i][j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != nil {
+				complaints = append(complaints, c)
+			}
+		}
+	}
+
+	for _, c := range complaints {
+		for _, tr := range trustees {
+			if err := tr.ProcessComplaint(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, c := range complaints {
+		if silent[c.Dealer] {
+			continue
+		}
+		j, err := trustees[c.Dealer-1].Justify(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range trustees {
+			if err := tr.ProcessJustification(j); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	keys := make([]*DistKey, n)
+	for i, tr := range trustees {
+		key, err := tr.Finalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+
+	return keys
+}
+
+// Reconstruct the secret at 0 from the shares of the given trustees.
+func reconstruct(suite kyber.Group, keys []*DistKey) kyber.Scalar {
+	secret := suite.Scalar().Zero()
+	for i, ki := range keys {
+		num := suite.Scalar().One()
+		den := suite.Scalar().One()
+		xi := suite.Scalar().SetInt64(int64(ki.Index))
+		for j, kj := range keys {
+			if i == j {
+				continue
+			}
+			xj := suite.Scalar().SetInt64(int64(kj.Index))
+			num.Mul(num, xj)
+			den.Mul(den, suite.Scalar().Sub(xj, xi))
+		}
+		secret.Add(secret, num.Mul(num, ki.Share).Div(num, den))
+	}
+	return secret
+}
+
+func checkKeys(t *testing.T, suite kyber.Group, keys []*DistKey, th int) {
+	for _, key := range keys {
+		if !key.Public.Equal(keys[0].Public) {
+			t.Fatal("trustees disagree on the election key")
+		}
+		if !suite.Point().Mul(key.Share, nil).Equal(key.Verification[key.Index-1]) {
+			t.Fatal("share does not match verification key")
+		}
+	}
+
+	// Any t trustees recover the secret key.
+	for i := 0; i+th <= len(keys); i++ {
+		secret := reconstruct(suite, keys[i:i+th])
+		if !suite.Point().Mul(secret, nil).Equal(keys[0].Public) {
+			t.Fatal("t shares do not recover the election key")
+		}
+	}
+
+	// Fewer do not.
+	secret := reconstruct(suite, keys[:th-1])
+	if suite.Point().Mul(secret, nil).Equal(keys[0].Public) {
+		t.Fatal("t-1 shares recover the election key")
+	}
+}
+
+func TestHonest(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	keys := simulate(t, suite, 5, 3, nil, nil)
+	checkKeys(t, suite, keys, 3)
+
+	if len(keys[0].Qualified) != 5 {
+		t.Fatal("honest dealer was disqualified")
+	}
+}
+
+func TestJustifiedComplaint(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	keys := simulate(t, suite, 5, 3, map[int]bool{2: true}, nil)
+	checkKeys(t, suite, keys, 3)
+
+	if len(keys[0].Qualified) != 5 {
+		t.Fatal("dealer answering complaint was disqualified")
+	}
+}
+
+func TestUnansweredComplaint(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	keys := simulate(t, suite, 5, 3, map[int]bool{2: true}, map[int]bool{2: true})
+	checkKeys(t, suite, keys, 3)
+
+	for _, key := range keys {
+		for _, dealer := range key.Qualified {
+			if dealer == 2 {
+				t.Fatal("dealer ignoring complaint was qualified")
+			}
+		}
+	}
+}
+
+func TestInvalidJustification(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	stream := suite.RandomStream()
+
+	dealer, _ := NewTrustee(suite, 1, 3, 2, stream)
+	tr, _ := NewTrustee(suite, 2, 3, 2, stream)
+
+	deal, _ := dealer.Deal()
+	if err := tr.ProcessDeal(deal); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Complaint{Dealer: 1, Complainer: 2}
+	if err := tr.ProcessComplaint(c); err != nil {
+		t.Fatal(err)
+	}
+	j, _ := dealer.Justify(c)
+	j.Value = suite.Scalar().Pick(stream)
+	if err := tr.ProcessJustification(j); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.Qualified()) != 0 {
+		t.Fatal("dealer with invalid justification was qualified")
+	}
+}
@@ -0,0 +1,14 @@
+/*
+Package dkg implements a Pedersen distributed key generation based on Feldman
+verifiable secret sharing, so that n trustees jointly generate the election key
+and any t of them can decrypt while no single party ever learns the secret key.
+
+Every trustee deals a random polynomial of degree t-1: it broadcasts a Deal
+containing commitments to the coefficients and privately sends every trustee
+its Share. A trustee receiving a share that does not match the commitments
+broadcasts a Complaint, which the dealer has to answer with a Justification
+revealing the disputed share. Dealers that fail to justify a complaint are
+disqualified and the election key is the sum of the secrets of all remaining
+dealers.
+*/
+package dkg
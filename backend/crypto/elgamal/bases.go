@@ -0,0 +1,128 @@
+package elgamal
+
+import (
+	"crypto/cipher"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/multiexp"
+	"github.com/qantik/evo/backend/crypto/permutation"
+)
+
+// Generator G and election key H with fixed-base tables. Encryption, every
+// re-encryption and the Neff prover multiply these two points by fresh
+// scalars over and over, so the tables are built once per election key and
+// shared, concurrent use is safe. The scalars are secret, the re-encryption
+// factors of a mix server link its input to its output, so the tables are
+// read without regard to the digits of the scalars, see multiexp.Table.
+type Bases struct {
+	G, H   kyber.Point
+	group  kyber.Group
+	gt, ht *multiexp.Table
+}
+
+// Precompute the tables of g and h.
+func NewBases(group kyber.Group, g, h kyber.Point) *Bases {
+	return &Bases{
+		G:     g,
+		H:     h,
+		group: group,
+		gt:    multiexp.NewTable(group, g),
+		ht:    multiexp.NewTable(group, h),
+	}
+}
+
+// Whether the tables belong to the points g and h.
+func (b *Bases) Match(g, h kyber.Point) bool {
+	return b.G.Equal(g) && b.H.Equal(h)
+}
+
+// Return s*G as a new point.
+func (b *Bases) MulG(s kyber.Scalar) kyber.Point {
+	if b.gt == nil {
+		return b.group.Point().Mul(s, b.G)
+	}
+	return b.gt.Mul(s)
+}
+
+// Return s*H as a new point.
+func (b *Bases) MulH(s kyber.Scalar) kyber.Point {
+	if b.ht == nil {
+		return b.group.Point().Mul(s, b.H)
+	}
+	return b.ht.Mul(s)
+}
+
+// Encrypt under H with respect to the generator G, see Encrypt.
+func (b *Bases) Encrypt(message []byte, stream cipher.Stream) (alpha,
+	beta kyber.Point, err error) {
+
+	if len(message) > b.group.Point().EmbedLen() {
+		return nil, nil, ErrPayloadTooLong
+	}
+	m := b.group.Point().Embed(message, stream)
+
+	y := b.group.Scalar().Pick(stream)
+	alpha = b.MulG(y)
+	beta = b.MulH(y)
+	beta.Add(beta, m)
+
+	return
+}
+
+// Re-encrypt under H and permute, see Permute.
+func (b *Bases) Permute(A, B []kyber.Point, stream cipher.Stream) (S,
+	T []kyber.Point, pi []int, beta []kyber.Scalar, err error) {
+
+	if len(A) != len(B) {
+		return nil, nil, nil, nil, ErrMismatchedLength
+	}
+
+	SS, TT, pi, betas, err := b.PermuteWide([][]kyber.Point{A},
+		[][]kyber.Point{B}, stream)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return SS[0], TT[0], pi, betas[0], nil
+}
+
+// Re-encrypt wide ciphertexts under H and permute their rows, see
+// PermuteWide.
+func (b *Bases) PermuteWide(A, B [][]kyber.Point, stream cipher.Stream) (S,
+	T [][]kyber.Point, pi []int, beta [][]kyber.Scalar, err error) {
+
+	if len(A) == 0 || len(A) != len(B) {
+		return nil, nil, nil, nil, ErrMismatchedLength
+	}
+	k := len(A[0])
+	for j := range A {
+		if len(A[j]) != k || len(B[j]) != k {
+			return nil, nil, nil, nil, ErrMismatchedLength
+		}
+	}
+
+	pi = permutation.Random(k, stream)
+
+	beta = make([][]kyber.Scalar, len(A))
+	for j := range beta {
+		beta[j] = make([]kyber.Scalar, k)
+		for i := 0; i < k; i++ {
+			beta[j][i] = b.group.Scalar().Pick(stream)
+		}
+	}
+
+	S = make([][]kyber.Point, len(A))
+	T = make([][]kyber.Point, len(A))
+	for j := range A {
+		S[j] = make([]kyber.Point, k)
+		T[j] = make([]kyber.Point, k)
+		for i := 0; i < k; i++ {
+			S[j][i] = b.MulG(beta[j][pi[i]])
+			S[j][i].Add(S[j][i], A[j][pi[i]])
+			T[j][i] = b.MulH(beta[j][pi[i]])
+			T[j][i].Add(T[j][i], B[j][pi[i]])
+		}
+	}
+
+	return
+}
@@ -0,0 +1,79 @@
+package elgamal
+
+import (
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func TestBases(t *testing.T) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			stream := suite.RandomStream()
+			key := GenerateKey(suite, stream)
+			g := suite.Point().Base()
+			bases := NewBases(suite, g, key.Public)
+
+			if !bases.Match(g, key.Public) || bases.Match(key.Public, g) {
+				t.Fatal("bases matched the wrong points")
+			}
+
+			const k = 5
+			A := make([]kyber.Point, k)
+			B := make([]kyber.Point, k)
+			for i := range A {
+				A[i], B[i], _ = bases.Encrypt([]byte(fmt.Sprint(i)), stream)
+				m, err := Decrypt(suite, key.Secret, A[i], B[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(m) != fmt.Sprint(i) {
+					t.Fatalf("pair %d decrypted to %q", i, m)
+				}
+			}
+
+			S, T, pi, beta, err := bases.Permute(A, B, stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPermutation(t, suite, g, key.Public, A, B, S, T, pi, beta)
+
+			if _, _, _, _, err := bases.Permute(A, B[:2], stream); err != ErrMismatchedLength {
+				t.Fatalf("mismatched vectors: %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkPermute(b *testing.B) {
+	const k = 100
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		suite, _ := suites.Lookup(name)
+		stream := suite.RandomStream()
+		key := GenerateKey(suite, stream)
+		g := suite.Point().Base()
+		bases := NewBases(suite, g, key.Public)
+
+		A := make([]kyber.Point, k)
+		B := make([]kyber.Point, k)
+		for i := range A {
+			A[i], B[i], _ = Encrypt(suite, key.Public, []byte(fmt.Sprint(i)), stream)
+		}
+
+		b.Run(name+"/direct", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Permute(suite, g, key.Public, A, B, stream)
+			}
+		})
+		b.Run(name+"/tables", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bases.Permute(A, B, stream)
+			}
+		})
+	}
+}
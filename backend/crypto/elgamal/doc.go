@@ -1,5 +1,7 @@
 /*
 Package elgamal implements the basic point encryption procedures as well as
-an encryption pair shuffling algorithm.
+an encryption pair shuffling algorithm, election key management and verifiable
+threshold decryption with Chaum-Pedersen proofs. Payloads longer than a single
+point are length-padded and encrypted as rows of several ciphertexts.
 */
 package elgamal
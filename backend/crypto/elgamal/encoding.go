@@ -1,21 +1,29 @@
 package elgamal
 
 import (
-	"gopkg.in/dedis/crypto.v0/abstract"
-	"gopkg.in/dedis/crypto.v0/random"
+	"crypto/cipher"
+
+	"go.dedis.ch/kyber/v3"
 )
 
-// Canonical ElGamal encryption. Returning encryption pair points.
+// Canonical ElGamal encryption. Returning encryption pair points. The
+// ephemeral key and the padding of the embedded message are drawn from stream.
+// A point embeds at most EmbedLen bytes and longer messages are rejected with
+// ErrPayloadTooLong, see EncryptRow for payloads of any length.
 // https://en.wikipedia.org/wiki/ElGamal_encryption#Encryption
-func Encrypt(suite abstract.Suite, public abstract.Point, message []byte) (
-	alpha, beta abstract.Point) {
+func Encrypt(group kyber.Group, public kyber.Point, message []byte,
+	stream cipher.Stream) (alpha, beta kyber.Point, err error) {
+
+	if len(message) > group.Point().EmbedLen() {
+		return nil, nil, ErrPayloadTooLong
+	}
 
 	// Map message onto group element
-	m, _ := suite.Point().Pick(message, random.Stream)
+	m := group.Point().Embed(message, stream)
 
-	y := suite.Scalar().Pick(random.Stream)
-	alpha = suite.Point().Mul(nil, y)
-	s := suite.Point().Mul(public, y)
+	y := group.Scalar().Pick(stream)
+	alpha = group.Point().Mul(y, nil)
+	s := group.Point().Mul(y, public)
 	beta = s.Add(s, m)
 
 	return
@@ -23,11 +31,11 @@ func Encrypt(suite abstract.Suite, public abstract.Point, message []byte) (
 
 // Canonical ElGamal decryption using the encryption pair points.
 // https://en.wikipedia.org/wiki/ElGamal_encryption#Decryption
-func Decrypt(suite abstract.Suite, secret abstract.Scalar, alpha, beta abstract.Point) (
+func Decrypt(group kyber.Group, secret kyber.Scalar, alpha, beta kyber.Point) (
 	message []byte, err error) {
 
-	s := suite.Point().Mul(alpha, secret)
-	m := suite.Point().Sub(beta, s)
+	s := group.Point().Mul(secret, alpha)
+	m := group.Point().Sub(beta, s)
 	message, err = m.Data()
 
 	return
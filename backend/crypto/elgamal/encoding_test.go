@@ -0,0 +1,59 @@
+package elgamal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	for _, name := range suites.Names() {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			stream := suite.RandomStream()
+			key := GenerateKey(suite, stream)
+
+			max := suite.Point().EmbedLen()
+			for _, n := range []int{0, 1, max / 2, max} {
+				msg := bytes.Repeat([]byte{'v'}, n)
+				alpha, beta, err := Encrypt(suite, key.Public, msg, stream)
+				if err != nil {
+					t.Fatal(err)
+				}
+				m, err := Decrypt(suite, key.Secret, alpha, beta)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(m, msg) {
+					t.Fatalf("%d byte message decrypted to %q", n, m)
+				}
+			}
+
+			// Longer messages are rejected rather than cut off.
+			long := bytes.Repeat([]byte{'v'}, max+1)
+			if _, _, err := Encrypt(suite, key.Public, long, stream); err != ErrPayloadTooLong {
+				t.Fatalf("oversize message: %v", err)
+			}
+			bases := NewBases(suite, suite.Point().Base(), key.Public)
+			if _, _, err := bases.Encrypt(long, stream); err != ErrPayloadTooLong {
+				t.Fatalf("oversize message with tables: %v", err)
+			}
+
+			// Encryption is randomized.
+			a1, b1, _ := Encrypt(suite, key.Public, []byte("vote"), stream)
+			a2, b2, _ := Encrypt(suite, key.Public, []byte("vote"), stream)
+			if a1.Equal(a2) || b1.Equal(b2) {
+				t.Fatal("equal ciphertexts for the same message")
+			}
+
+			// A different key does not recover the message.
+			other := GenerateKey(suite, stream)
+			m, err := Decrypt(suite, other.Secret, a1, b1)
+			if err == nil && string(m) == "vote" {
+				t.Fatal("ciphertext decrypted under the wrong key")
+			}
+		})
+	}
+}
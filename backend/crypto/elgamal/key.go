@@ -0,0 +1,120 @@
+package elgamal
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// Election key pair. Ballots are encrypted and re-encrypted under the public
+// key, the secret key is held by the authority decrypting the mix output.
+// Secret is nil for keys that were loaded from a public key file.
+type KeyPair struct {
+	Secret kyber.Scalar
+	Public kyber.Point
+}
+
+// On-disk key format, a single JSON object:
+//
+//	{
+//	  "suite":  "P256",
+//	  "public": "<hex encoded point>",
+//	  "secret": "<hex encoded scalar>"
+//	}
+//
+// The suite entry is the String() of the suite the key belongs to, points and
+// scalars are hex encodings of their MarshalBinary output. The secret entry
+// is omitted in public key files handed out to voters and mix servers.
+type keyFile struct {
+	Suite  string `json:"suite"`
+	Public string `json:"public"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// Generate a fresh election key pair.
+func GenerateKey(group kyber.Group, stream cipher.Stream) *KeyPair {
+	secret := group.Scalar().Pick(stream)
+	public := group.Point().Mul(secret, nil)
+
+	return &KeyPair{Secret: secret, Public: public}
+}
+
+// Write the key pair including the secret key.
+func (key *KeyPair) Write(group kyber.Group, w io.Writer) error {
+	return key.write(group, w, true)
+}
+
+// Write only the public part of the key pair.
+func (key *KeyPair) WritePublic(group kyber.Group, w io.Writer) error {
+	return key.write(group, w, false)
+}
+
+func (key *KeyPair) write(group kyber.Group, w io.Writer, secret bool) error {
+	file := keyFile{Suite: group.String()}
+
+	public, err := key.Public.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	file.Public = hex.EncodeToString(public)
+
+	if secret {
+		if key.Secret == nil {
+			return errors.New("key pair has no secret key")
+		}
+		buf, err := key.Secret.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		file.Secret = hex.EncodeToString(buf)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
+
+// Read a key pair written by Write or WritePublic. The secret key is only
+// set if it is present in the file.
+func ReadKey(group kyber.Group, r io.Reader) (*KeyPair, error) {
+	var file keyFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	if file.Suite != group.String() {
+		return nil, errors.New("key belongs to suite " + file.Suite)
+	}
+
+	key := &KeyPair{}
+
+	buf, err := hex.DecodeString(file.Public)
+	if err != nil {
+		return nil, err
+	}
+	key.Public = group.Point()
+	if err := key.Public.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+
+	if file.Secret == "" {
+		return key, nil
+	}
+
+	buf, err = hex.DecodeString(file.Secret)
+	if err != nil {
+		return nil, err
+	}
+	key.Secret = group.Scalar()
+	if err := key.Secret.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+	if !group.Point().Mul(key.Secret, nil).Equal(key.Public) {
+		return nil, errors.New("secret key does not match public key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,82 @@
+package elgamal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func TestKeyRoundTrip(t *testing.T) {
+	for _, name := range suites.Names() {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			key := GenerateKey(suite, suite.RandomStream())
+
+			var buf bytes.Buffer
+			if err := key.Write(suite, &buf); err != nil {
+				t.Fatal(err)
+			}
+			dec, err := ReadKey(suite, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dec.Secret.Equal(key.Secret) || !dec.Public.Equal(key.Public) {
+				t.Fatal("key pair changed in round trip")
+			}
+
+			buf.Reset()
+			if err := key.WritePublic(suite, &buf); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(buf.String(), "secret") {
+				t.Fatal("public key file holds the secret key")
+			}
+			pub, err := ReadKey(suite, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pub.Secret != nil || !pub.Public.Equal(key.Public) {
+				t.Fatal("public key changed in round trip")
+			}
+			if pub.Write(suite, &buf) == nil {
+				t.Fatal("secret key written without a secret")
+			}
+		})
+	}
+}
+
+func TestKeyRejected(t *testing.T) {
+	suite, _ := suites.Lookup("P256")
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	key := GenerateKey(suite, suite.RandomStream())
+	other := GenerateKey(suite, suite.RandomStream())
+
+	write := func(key *KeyPair) string {
+		var buf bytes.Buffer
+		if err := key.Write(suite, &buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	file := write(key)
+	ed25519, _ := suites.Lookup("Ed25519")
+
+	for name, c := range map[string]struct {
+		file  string
+		suite suites.Suite
+	}{
+		"mismatched pair": {write(&KeyPair{Secret: other.Secret, Public: key.Public}), suite},
+		"unknown suite":   {strings.Replace(file, `"P256"`, `"P255"`, 1), suite},
+		"other suite":     {file, ed25519},
+		"malformed hex":   {strings.Replace(file, `"public": "`, `"public": "x`, 1), suite},
+		"invalid point":   {strings.Replace(file, `"public": "04`, `"public": "05`, 1), suite},
+		"truncated":       {file[:len(file)/2], suite},
+	} {
+		if _, err := ReadKey(c.suite, strings.NewReader(c.file)); err == nil {
+			t.Fatalf("%s accepted", name)
+		}
+	}
+}
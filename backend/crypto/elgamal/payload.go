@@ -0,0 +1,140 @@
+package elgamal
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+var (
+	// A payload does not fit into a row of the requested width.
+	ErrPayloadTooLong = errors.New("payload exceeds ciphertext row capacity")
+	// Decrypted chunks do not hold a padded payload.
+	ErrMalformedPayload = errors.New("malformed padded payload")
+)
+
+// Bytes of the big-endian length prefix of a padded payload.
+const prefixLen = 2
+
+// Number of payload bytes a row of width ciphertexts holds: the embedding
+// capacity of all its points less the length prefix.
+func Capacity(group kyber.Group, width int) int {
+	n := width*group.Point().EmbedLen() - prefixLen
+	if n < 0 {
+		return 0
+	}
+	if n > 0xffff {
+		return 0xffff
+	}
+	return n
+}
+
+// Smallest width of a row that holds a payload of n bytes.
+func Width(group kyber.Group, n int) (int, error) {
+	if n > 0xffff {
+		return 0, ErrPayloadTooLong
+	}
+	l := group.Point().EmbedLen()
+	return (n + prefixLen + l - 1) / l, nil
+}
+
+// Split a payload into width chunks of the embedding capacity of the group
+// each. The payload is prefixed with its length and padded with zeros, so
+// that all rows of the same width look alike no matter how long their
+// payloads are. Payloads longer than Capacity are rejected.
+func Pad(group kyber.Group, payload []byte, width int) ([][]byte, error) {
+	if width < 1 {
+		return nil, ErrMismatchedLength
+	}
+	if len(payload) > Capacity(group, width) {
+		return nil, ErrPayloadTooLong
+	}
+
+	l := group.Point().EmbedLen()
+	buf := make([]byte, width*l)
+	binary.BigEndian.PutUint16(buf, uint16(len(payload)))
+	copy(buf[prefixLen:], payload)
+
+	chunks := make([][]byte, width)
+	for j := range chunks {
+		chunks[j] = buf[j*l : (j+1)*l]
+	}
+	return chunks, nil
+}
+
+// Reassemble a payload from the chunks made by Pad.
+func Unpad(group kyber.Group, chunks [][]byte) ([]byte, error) {
+	l := group.Point().EmbedLen()
+	buf := make([]byte, 0, len(chunks)*l)
+	for _, chunk := range chunks {
+		if len(chunk) != l {
+			return nil, ErrMalformedPayload
+		}
+		buf = append(buf, chunk...)
+	}
+	if len(buf) < prefixLen {
+		return nil, ErrMalformedPayload
+	}
+
+	n := int(binary.BigEndian.Uint16(buf))
+	if n > len(buf)-prefixLen {
+		return nil, ErrMalformedPayload
+	}
+	for _, b := range buf[prefixLen+n:] {
+		if b != 0 {
+			return nil, ErrMalformedPayload
+		}
+	}
+	return buf[prefixLen : prefixLen+n], nil
+}
+
+// Encrypt a payload of arbitrary structure, such as a JSON ranked choice or a
+// write-in, as a row of width ciphertexts. alpha[j] and beta[j] are the j-th
+// pair of the row, the column j of a wide shuffle, see PermuteWide.
+func EncryptRow(group kyber.Group, public kyber.Point, payload []byte,
+	width int, stream cipher.Stream) (alpha, beta []kyber.Point, err error) {
+
+	bases := &Bases{G: group.Point().Base(), H: public, group: group}
+	return bases.EncryptRow(payload, width, stream)
+}
+
+// Encrypt a payload as a row of width ciphertexts under H with respect to
+// the generator G, see EncryptRow.
+func (b *Bases) EncryptRow(payload []byte, width int, stream cipher.Stream) (
+	alpha, beta []kyber.Point, err error) {
+
+	chunks, err := Pad(b.group, payload, width)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	alpha = make([]kyber.Point, width)
+	beta = make([]kyber.Point, width)
+	for j, chunk := range chunks {
+		if alpha[j], beta[j], err = b.Encrypt(chunk, stream); err != nil {
+			return nil, nil, err
+		}
+	}
+	return
+}
+
+// Decrypt a row made by EncryptRow and reassemble its payload.
+func DecryptRow(group kyber.Group, secret kyber.Scalar, alpha,
+	beta []kyber.Point) ([]byte, error) {
+
+	if len(alpha) != len(beta) {
+		return nil, ErrMismatchedLength
+	}
+
+	chunks := make([][]byte, len(alpha))
+	for j := range alpha {
+		chunk, err := Decrypt(group, secret, alpha[j], beta[j])
+		if err != nil {
+			return nil, err
+		}
+		chunks[j] = chunk
+	}
+	return Unpad(group, chunks)
+}
@@ -0,0 +1,170 @@
+package elgamal
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func TestPad(t *testing.T) {
+	suite, _ := suites.Lookup("P256")
+	l := suite.Point().EmbedLen()
+
+	for _, width := range []int{1, 2, 5} {
+		max := Capacity(suite, width)
+		if max != width*l-prefixLen {
+			t.Fatalf("capacity %d of width %d", max, width)
+		}
+		for _, n := range []int{0, 1, max} {
+			payload := bytes.Repeat([]byte{'p'}, n)
+			chunks, err := Pad(suite, payload, width)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != width {
+				t.Fatalf("%d chunks for width %d", len(chunks), width)
+			}
+			for _, chunk := range chunks {
+				if len(chunk) != l {
+					t.Fatalf("chunk of %d bytes", len(chunk))
+				}
+			}
+			m, err := Unpad(suite, chunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(m, payload) {
+				t.Fatalf("%d byte payload unpadded to %d bytes", n, len(m))
+			}
+		}
+
+		if _, err := Pad(suite, make([]byte, max+1), width); err != ErrPayloadTooLong {
+			t.Fatalf("oversize payload: %v", err)
+		}
+		if w, err := Width(suite, max); err != nil || w != width {
+			t.Fatalf("width %d for a payload of width %d: %v", w, width, err)
+		}
+		if w, _ := Width(suite, max+1); w != width+1 {
+			t.Fatalf("width %d for a payload exceeding width %d", w, width)
+		}
+	}
+	if _, err := Width(suite, 0x10000); err != ErrPayloadTooLong {
+		t.Fatalf("payload beyond the length prefix: %v", err)
+	}
+	if _, err := Pad(suite, nil, 0); err != ErrMismatchedLength {
+		t.Fatalf("zero width: %v", err)
+	}
+
+	chunks, _ := Pad(suite, []byte("vote"), 2)
+	for _, c := range []struct {
+		name   string
+		chunks [][]byte
+	}{
+		{"no chunks", nil},
+		{"short chunk", [][]byte{chunks[0], chunks[1][:l-1]}},
+		{"excess length", [][]byte{append([]byte{0xff, 0xff}, chunks[0][2:]...), chunks[1]}},
+		{"nonzero padding", [][]byte{chunks[0], append(make([]byte, l-1), 1)}},
+	} {
+		if _, err := Unpad(suite, c.chunks); err != ErrMalformedPayload {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+	}
+}
+
+func TestEncryptRow(t *testing.T) {
+	payload := []byte(`{"ranking":["alice","bob","carol"],"write-in":"dave the dolphin"}`)
+
+	for _, name := range suites.Names() {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			stream := suite.RandomStream()
+			key := GenerateKey(suite, stream)
+
+			width := (len(payload)+prefixLen)/suite.Point().EmbedLen() + 1
+			alpha, beta, err := EncryptRow(suite, key.Public, payload, width, stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(alpha) != width || len(beta) != width {
+				t.Fatalf("row of %d ciphertexts for width %d", len(alpha), width)
+			}
+			m, err := DecryptRow(suite, key.Secret, alpha, beta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(m, payload) {
+				t.Fatalf("row decrypted to %q", m)
+			}
+
+			bases := NewBases(suite, suite.Point().Base(), key.Public)
+			alpha, beta, err = bases.EncryptRow(payload, width, stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m, err := DecryptRow(suite, key.Secret, alpha, beta); err != nil ||
+				!bytes.Equal(m, payload) {
+				t.Fatalf("row encrypted with tables decrypted to %q: %v", m, err)
+			}
+
+			if width > 1 {
+				if _, _, err := EncryptRow(suite, key.Public, payload, width-1, stream); err != ErrPayloadTooLong {
+					t.Fatalf("narrow row: %v", err)
+				}
+			}
+			if _, err := DecryptRow(suite, key.Secret, alpha, beta[1:]); err != ErrMismatchedLength {
+				t.Fatalf("mismatched row: %v", err)
+			}
+		})
+	}
+}
+
+// Rows survive a wide shuffle of their columns.
+func TestShuffleRows(t *testing.T) {
+	suite, _ := suites.Lookup("Ed25519")
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	key := GenerateKey(suite, stream)
+	g := suite.Point().Base()
+
+	k, width := 5, 3
+	A := make([][]kyber.Point, width)
+	B := make([][]kyber.Point, width)
+	for j := range A {
+		A[j] = make([]kyber.Point, k)
+		B[j] = make([]kyber.Point, k)
+	}
+	for i := 0; i < k; i++ {
+		payload := bytes.Repeat([]byte(fmt.Sprint(i)), 20*i+1)
+		alpha, beta, err := EncryptRow(suite, key.Public, payload, width, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range alpha {
+			A[j][i], B[j][i] = alpha[j], beta[j]
+		}
+	}
+
+	S, T, pi, _, err := PermuteWide(suite, g, key.Public, A, B, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < k; i++ {
+		alpha := make([]kyber.Point, width)
+		beta := make([]kyber.Point, width)
+		for j := range alpha {
+			alpha[j], beta[j] = S[j][i], T[j][i]
+		}
+		m, err := DecryptRow(suite, key.Secret, alpha, beta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m, bytes.Repeat([]byte(fmt.Sprint(pi[i])), 20*pi[i]+1)) {
+			t.Fatalf("row %d decrypted to %q, expected row %d", i, m, pi[i])
+		}
+	}
+}
@@ -2,48 +2,60 @@ package elgamal
 
 import (
 	"crypto/cipher"
+	"errors"
 
-	"gopkg.in/dedis/crypto.v0/abstract"
-	"gopkg.in/dedis/crypto.v0/random"
+	"go.dedis.ch/kyber/v3"
 )
 
-// Shuffle ElGamal pair vectors using the Fisher-Yates algorithm.
+var (
+	// Pair vectors or their secrets are of inconsistent length.
+	ErrMismatchedLength = errors.New("mismatched vector lengths")
+	// A shuffle needs at least two ciphertexts.
+	ErrTooFewCiphertexts = errors.New("too few ciphertexts to shuffle")
+)
+
+// Shuffle ElGamal pair vectors with a uniformly random permutation.
 // Returns permuted pair vectors, the permutation array and the blinding factors.
-func Permute(group abstract.Group, g, w abstract.Point, A, B []abstract.Point,
-	stream cipher.Stream) (S, T []abstract.Point, pi []int, beta []abstract.Scalar) {
+func Permute(group kyber.Group, g, w kyber.Point, A, B []kyber.Point,
+	stream cipher.Stream) (S, T []kyber.Point, pi []int, beta []kyber.Scalar,
+	err error) {
 
-	k := len(A)
-	if k != len(B) {
-		panic("Pair vectors have inconsistent length")
-	}
+	bases := &Bases{G: g, H: w, group: group}
+	return bases.Permute(A, B, stream)
+}
 
-	pi = make([]int, k)
-	for i := 0; i < k; i++ {
-		pi[i] = i
-	}
+// Shuffle the rows of wide ciphertexts, as for ballots with several contests.
+// The pair vectors are given column by column, A[j][i] and B[j][i] are the
+// j-th pair of row i. All columns are permuted with the same uniformly random
+// permutation and every pair is re-encrypted with its own blinding factor,
+// beta[j][i] for the pair A[j][i], B[j][i].
+func PermuteWide(group kyber.Group, g, w kyber.Point, A, B [][]kyber.Point,
+	stream cipher.Stream) (S, T [][]kyber.Point, pi []int,
+	beta [][]kyber.Scalar, err error) {
+
+	bases := &Bases{G: g, H: w, group: group}
+	return bases.PermuteWide(A, B, stream)
+}
 
-	for i := k - 1; i > 0; i-- {
-		j := int(random.Uint64(stream) % uint64(i+1))
-		if j != i {
-			t := pi[j]
-			pi[j] = pi[i]
-			pi[i] = t
-		}
+// Split a vector holding width columns of equal length one after the other
+// into its columns, the layout of wide shuffles in transcripts.
+func Columns(V []kyber.Point, width int) ([][]kyber.Point, error) {
+	if width < 1 || len(V)%width != 0 {
+		return nil, ErrMismatchedLength
 	}
-
-	beta = make([]abstract.Scalar, k)
-	for i := 0; i < k; i++ {
-		beta[i] = group.Scalar().Pick(stream)
+	k := len(V) / width
+	columns := make([][]kyber.Point, width)
+	for j := range columns {
+		columns[j] = V[j*k : (j+1)*k]
 	}
+	return columns, nil
+}
 
-	S = make([]abstract.Point, k)
-	T = make([]abstract.Point, k)
-	for i := 0; i < k; i++ {
-		S[i] = group.Point().Mul(g, beta[pi[i]])
-		S[i].Add(S[i], A[pi[i]])
-		T[i] = group.Point().Mul(w, beta[pi[i]])
-		T[i].Add(T[i], B[pi[i]])
+// Lay out the columns one after the other, see Columns.
+func Flatten(columns [][]kyber.Point) []kyber.Point {
+	var V []kyber.Point
+	for _, column := range columns {
+		V = append(V, column...)
 	}
-
-	return
+	return V
 }
@@ -0,0 +1,168 @@
+package elgamal
+
+import (
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+// Check that pi is a permutation and that (S, T) re-encrypts the pairs
+// (A, B) in the order given by pi with the blinding factors beta.
+func checkPermutation(t *testing.T, group kyber.Group, g, w kyber.Point,
+	A, B, S, T []kyber.Point, pi []int, beta []kyber.Scalar) {
+
+	k := len(A)
+	if len(S) != k || len(T) != k || len(pi) != k || len(beta) != k {
+		t.Fatal("output of wrong length")
+	}
+
+	seen := make([]bool, k)
+	for _, j := range pi {
+		if j < 0 || j >= k || seen[j] {
+			t.Fatalf("%v is not a permutation", pi)
+		}
+		seen[j] = true
+	}
+
+	P := group.Point()
+	for i := 0; i < k; i++ {
+		P.Mul(beta[pi[i]], g)
+		if !P.Add(P, A[pi[i]]).Equal(S[i]) {
+			t.Fatalf("S[%d] is not a re-encryption of A[%d]", i, pi[i])
+		}
+		P.Mul(beta[pi[i]], w)
+		if !P.Add(P, B[pi[i]]).Equal(T[i]) {
+			t.Fatalf("T[%d] is not a re-encryption of B[%d]", i, pi[i])
+		}
+	}
+}
+
+func TestPermute(t *testing.T) {
+	suite, _ := suites.Lookup(suites.Default)
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	key := GenerateKey(suite, stream)
+	g := suite.Point().Base()
+
+	for k := 1; k <= 12; k++ {
+		A := make([]kyber.Point, k)
+		B := make([]kyber.Point, k)
+		for i := range A {
+			A[i], B[i], _ = Encrypt(suite, key.Public, []byte(fmt.Sprint(i)), stream)
+		}
+
+		S, T, pi, beta, err := Permute(suite, g, key.Public, A, B, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPermutation(t, suite, g, key.Public, A, B, S, T, pi, beta)
+
+		// The shuffled pairs decrypt to the permuted messages.
+		for i := range S {
+			m, err := Decrypt(suite, key.Secret, S[i], T[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(m) != fmt.Sprint(pi[i]) {
+				t.Fatalf("pair %d decrypted to %q, expected %d", i, m, pi[i])
+			}
+		}
+	}
+
+	A := make([]kyber.Point, 3)
+	if _, _, _, _, err := Permute(suite, g, key.Public, A, A[:2], stream); err != ErrMismatchedLength {
+		t.Fatalf("mismatched vectors: %v", err)
+	}
+}
+
+// All columns of wide ciphertexts are shuffled with the same permutation.
+func TestPermuteWide(t *testing.T) {
+	suite, _ := suites.Lookup(suites.Default)
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	key := GenerateKey(suite, stream)
+	g := suite.Point().Base()
+
+	k, width := 6, 3
+	A := make([][]kyber.Point, width)
+	B := make([][]kyber.Point, width)
+	for j := range A {
+		A[j] = make([]kyber.Point, k)
+		B[j] = make([]kyber.Point, k)
+		for i := range A[j] {
+			A[j][i], B[j][i], _ = Encrypt(suite, key.Public,
+				[]byte(fmt.Sprint(i, j)), stream)
+		}
+	}
+
+	S, T, pi, beta, err := PermuteWide(suite, g, key.Public, A, B, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(S) != width || len(T) != width || len(beta) != width {
+		t.Fatal("output of wrong width")
+	}
+	for j := range A {
+		checkPermutation(t, suite, g, key.Public, A[j], B[j], S[j], T[j], pi, beta[j])
+	}
+
+	flat := Flatten(S)
+	if len(flat) != width*k || !flat[k].Equal(S[1][0]) {
+		t.Fatal("columns not laid out one after the other")
+	}
+	columns, err := Columns(flat, width)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := range columns {
+		for i := range columns[j] {
+			if !columns[j][i].Equal(S[j][i]) {
+				t.Fatalf("pair %d of column %d changed", i, j)
+			}
+		}
+	}
+	if _, err := Columns(flat[1:], width); err != ErrMismatchedLength {
+		t.Fatalf("uneven columns: %v", err)
+	}
+
+	for _, c := range []struct{ A, B [][]kyber.Point }{
+		{nil, nil},
+		{A, B[:2]},
+		{A, [][]kyber.Point{B[0], B[1], B[2][:5]}},
+	} {
+		if _, _, _, _, err := PermuteWide(suite, g, key.Public, c.A, c.B, stream); err != ErrMismatchedLength {
+			t.Fatalf("mismatched columns: %v", err)
+		}
+	}
+}
+
+// Every permutation of three pairs is drawn with roughly equal frequency.
+func TestPermuteCoverage(t *testing.T) {
+	suite, _ := suites.Lookup("Ed25519")
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	g := suite.Point().Base()
+
+	A := []kyber.Point{suite.Point().Null(), suite.Point().Null(), suite.Point().Null()}
+	counts := make(map[string]int)
+	n := 600
+	for i := 0; i < n; i++ {
+		_, _, pi, _, err := Permute(suite, g, g, A, A, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[fmt.Sprint(pi)]++
+	}
+
+	if len(counts) != 6 {
+		t.Fatalf("only %d of 6 permutations drawn: %v", len(counts), counts)
+	}
+	for pi, c := range counts {
+		if c < n/12 || c > n/3 {
+			t.Fatalf("permutation %s drawn %d out of %d times", pi, c, n)
+		}
+	}
+}
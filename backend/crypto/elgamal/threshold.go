@@ -0,0 +1,204 @@
+package elgamal
+
+import (
+	"errors"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+)
+
+// Partial decryption alpha^x_i of trustee Index together with a
+// non-interactive Chaum-Pedersen proof that log_g(g^x_i) = log_alpha(D).
+type PartialDecryption struct {
+	Index int
+	D     kyber.Point
+	Proof []byte
+}
+
+// P (Prover) step 0: statement the proof is bound to
+type cp0 struct {
+	Y, Alpha, D kyber.Point
+}
+
+// P step 1: commitments g^r and alpha^r
+type cp1 struct {
+	A, B kyber.Point
+}
+
+// V (Verifier) step 2: random challenge c
+type cp2 struct {
+	Zc kyber.Scalar
+}
+
+// P step 3: response r - c*x
+type cp3 struct {
+	Zr kyber.Scalar
+}
+
+// Chaum-Pedersen proof of equality of discrete logarithms of Y = g^x and
+// D = alpha^x.
+type chaumPedersen struct {
+	p0 cp0
+	p1 cp1
+	v2 cp2
+	p3 cp3
+}
+
+func (cp *chaumPedersen) prove(suite proof.Suite, x kyber.Scalar,
+	ctx proof.ProverContext) error {
+
+	if err := ctx.Put(&cp.p0); err != nil {
+		return err
+	}
+
+	r := suite.Scalar()
+	if err := ctx.PriRand(r); err != nil {
+		return err
+	}
+	cp.p1.A = suite.Point().Mul(r, nil)
+	cp.p1.B = suite.Point().Mul(r, cp.p0.Alpha)
+	if err := ctx.Put(&cp.p1); err != nil {
+		return err
+	}
+
+	if err := ctx.PubRand(&cp.v2); err != nil {
+		return err
+	}
+
+	cp.p3.Zr = suite.Scalar().Mul(cp.v2.Zc, x)
+	cp.p3.Zr.Sub(r, cp.p3.Zr)
+	return ctx.Put(&cp.p3)
+}
+
+func (cp *chaumPedersen) verify(suite proof.Suite, ctx proof.VerifierContext) error {
+	var p0 cp0
+	if err := ctx.Get(&p0); err != nil {
+		return err
+	}
+	if !p0.Y.Equal(cp.p0.Y) || !p0.Alpha.Equal(cp.p0.Alpha) || !p0.D.Equal(cp.p0.D) {
+		return errors.New("ChaumPedersenProof for different statement")
+	}
+	if err := ctx.Get(&cp.p1); err != nil {
+		return err
+	}
+	if err := ctx.PubRand(&cp.v2); err != nil {
+		return err
+	}
+	if err := ctx.Get(&cp.p3); err != nil {
+		return err
+	}
+
+	// g^r = g^(r-cx) * Y^c and alpha^r = alpha^(r-cx) * D^c
+	P := suite.Point().Mul(cp.p3.Zr, nil)
+	P.Add(P, suite.Point().Mul(cp.v2.Zc, cp.p0.Y))
+	Q := suite.Point().Mul(cp.p3.Zr, cp.p0.Alpha)
+	Q.Add(Q, suite.Point().Mul(cp.v2.Zc, cp.p0.D))
+	if !P.Equal(cp.p1.A) || !Q.Equal(cp.p1.B) {
+		return errors.New("invalid ChaumPedersenProof")
+	}
+
+	return nil
+}
+
+// Compute the partial decryption of the encryption pair (alpha, beta) with
+// the secret share x_i of trustee index.
+func PartialDecrypt(suite proof.Suite, index int, share kyber.Scalar,
+	alpha kyber.Point) (*PartialDecryption, error) {
+
+	cp := chaumPedersen{}
+	cp.p0.Y = suite.Point().Mul(share, nil)
+	cp.p0.Alpha = alpha
+	cp.p0.D = suite.Point().Mul(share, alpha)
+
+	prover := func(ctx proof.ProverContext) error {
+		return cp.prove(suite, share, ctx)
+	}
+	stamp, err := proof.HashProve(suite, "CP", prover)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PartialDecryption{Index: index, D: cp.p0.D, Proof: stamp}, nil
+}
+
+// Check the proof of a partial decryption of alpha against the public
+// verification key g^x_i of the trustee.
+func VerifyPartial(suite proof.Suite, verification, alpha kyber.Point,
+	partial *PartialDecryption) error {
+
+	cp := chaumPedersen{}
+	cp.p0.Y = verification
+	cp.p0.Alpha = alpha
+	cp.p0.D = partial.D
+
+	verifier := func(ctx proof.VerifierContext) error {
+		return cp.verify(suite, ctx)
+	}
+	return proof.HashVerify(suite, "CP", verifier, partial.Proof)
+}
+
+// Lagrange coefficient at 0 of trustee index i for the given index set.
+func lagrange(group kyber.Group, indices []int, i int) kyber.Scalar {
+	num := group.Scalar().One()
+	den := group.Scalar().One()
+	xi := group.Scalar().SetInt64(int64(i))
+	for _, j := range indices {
+		if j == i {
+			continue
+		}
+		xj := group.Scalar().SetInt64(int64(j))
+		num.Mul(num, xj)
+		den.Mul(den, group.Scalar().Sub(xj, xi))
+	}
+	return num.Div(num, den)
+}
+
+// Combine t partial decryptions of (alpha, beta) into the plaintext.
+// verification[i-1] is the verification key g^x_i of trustee i. Partial
+// decryptions that are incomplete or have invalid proofs or duplicate indices
+// are skipped, an error is returned if fewer than t valid ones remain. The
+// threshold t lies between 1 and the number of trustees.
+func Combine(suite proof.Suite, t int, verification []kyber.Point,
+	alpha, beta kyber.Point, partials []*PartialDecryption) ([]byte, error) {
+
+	if t < 1 || t > len(verification) {
+		return nil, errors.New("threshold out of range")
+	}
+
+	var valid []*PartialDecryption
+	seen := make(map[int]bool)
+	for _, partial := range partials {
+		if len(valid) == t {
+			break
+		}
+		if partial == nil || partial.D == nil || partial.Proof == nil {
+			continue
+		}
+		i := partial.Index
+		if i < 1 || i > len(verification) || seen[i] || verification[i-1] == nil {
+			continue
+		}
+		if VerifyPartial(suite, verification[i-1], alpha, partial) != nil {
+			continue
+		}
+		seen[i] = true
+		valid = append(valid, partial)
+	}
+	if len(valid) < t {
+		return nil, errors.New("fewer than t valid partial decryptions")
+	}
+
+	indices := make([]int, t)
+	for j, partial := range valid {
+		indices[j] = partial.Index
+	}
+
+	s := suite.Point().Null()
+	P := suite.Point()
+	for _, partial := range valid {
+		s.Add(s, P.Mul(lagrange(suite, indices, partial.Index), partial.D))
+	}
+	m := suite.Point().Sub(beta, s)
+
+	return m.Data()
+}
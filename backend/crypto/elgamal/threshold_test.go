@@ -0,0 +1,145 @@
+package elgamal
+
+import (
+	"crypto/cipher"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/nist"
+)
+
+// Shamir share a fresh secret among n trustees with threshold t.
+func share(group kyber.Group, n, t int, stream cipher.Stream) (
+	public kyber.Point, shares []kyber.Scalar, verification []kyber.Point) {
+
+	poly := make([]kyber.Scalar, t)
+	for k := range poly {
+		poly[k] = group.Scalar().Pick(stream)
+	}
+	public = group.Point().Mul(poly[0], nil)
+
+	shares = make([]kyber.Scalar, n)
+	verification = make([]kyber.Point, n)
+	for i := 1; i <= n; i++ {
+		x := group.Scalar().SetInt64(int64(i))
+		v := group.Scalar().Set(poly[t-1])
+		for k := t - 2; k >= 0; k-- {
+			v.Mul(v, x).Add(v, poly[k])
+		}
+		shares[i-1] = v
+		verification[i-1] = group.Point().Mul(v, nil)
+	}
+
+	return
+}
+
+func TestThresholdDecryption(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	stream := suite.RandomStream()
+	public, shares, verification := share(suite, 5, 3, stream)
+
+	alpha, beta, _ := Encrypt(suite, public, []byte("ballot"), stream)
+
+	partials := make([]*PartialDecryption, 0, 5)
+	for i := 5; i >= 1; i-- {
+		partial, err := PartialDecrypt(suite, i, shares[i-1], alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyPartial(suite, verification[i-1], alpha, partial); err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, partial)
+	}
+
+	m, err := Combine(suite, 3, verification, alpha, beta, partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m) != "ballot" {
+		t.Fatalf("decrypted %q", m)
+	}
+}
+
+func TestInvalidPartialDecryption(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	stream := suite.RandomStream()
+	public, shares, verification := share(suite, 4, 3, stream)
+
+	alpha, beta, _ := Encrypt(suite, public, []byte("ballot"), stream)
+
+	partials := make([]*PartialDecryption, 4)
+	for i := 1; i <= 4; i++ {
+		partials[i-1], _ = PartialDecrypt(suite, i, shares[i-1], alpha)
+	}
+
+	// Trustee 1 lies about its partial decryption while reusing its proof.
+	partials[0].D = suite.Point().Add(partials[0].D, public)
+	if VerifyPartial(suite, verification[0], alpha, partials[0]) == nil {
+		t.Fatal("forged partial decryption verified")
+	}
+
+	// A proof made with another trustee's share does not verify either.
+	if VerifyPartial(suite, verification[2], alpha, partials[1]) == nil {
+		t.Fatal("partial decryption verified under wrong key")
+	}
+
+	// The forged share is skipped and the remaining three suffice.
+	m, err := Combine(suite, 3, verification, alpha, beta, partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m) != "ballot" {
+		t.Fatalf("decrypted %q", m)
+	}
+
+	if _, err := Combine(suite, 3, verification, alpha, beta, partials[:3]); err == nil {
+		t.Fatal("combined fewer than t valid partial decryptions")
+	}
+}
+
+func TestCombineThreshold(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	stream := suite.RandomStream()
+	public, shares, verification := share(suite, 3, 2, stream)
+
+	alpha, beta, _ := Encrypt(suite, public, []byte("ballot"), stream)
+
+	partials := make([]*PartialDecryption, 3)
+	for i := 1; i <= 3; i++ {
+		partials[i-1], _ = PartialDecrypt(suite, i, shares[i-1], alpha)
+	}
+	for _, th := range []int{-1, 0, 4} {
+		if _, err := Combine(suite, th, verification, alpha, beta, partials); err == nil {
+			t.Fatalf("threshold %d accepted", th)
+		}
+	}
+}
+
+func TestCombineIncomplete(t *testing.T) {
+	suite := nist.NewBlakeSHA256P256()
+	stream := suite.RandomStream()
+	public, shares, verification := share(suite, 5, 2, stream)
+
+	alpha, beta, _ := Encrypt(suite, public, []byte("ballot"), stream)
+
+	partials := make([]*PartialDecryption, 5)
+	for i := 1; i <= 5; i++ {
+		partials[i-1], _ = PartialDecrypt(suite, i, shares[i-1], alpha)
+	}
+	partials[0] = nil
+	partials[1].D = nil
+	partials[2].Proof = nil
+
+	// The incomplete ones are skipped, the last two suffice.
+	m, err := Combine(suite, 2, verification, alpha, beta, partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(m) != "ballot" {
+		t.Fatalf("decrypted %q", m)
+	}
+	if _, err := Combine(suite, 2, verification, alpha, beta, partials[:4]); err == nil {
+		t.Fatal("combined incomplete partial decryptions")
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Package mixnet chains mix servers into a cascade. Every mix server shuffles
+the output of its predecessor under the election key and publishes a Neff
+shuffle proof, so that the cascade output is unlinkable to its input as long
+as a single mix server is honest. Ciphertexts wider than a single pair are
+mixed column by column under one permutation per hop, see
+elgamal.PermuteWide.
+*/
+package mixnet
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"time"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/neff"
+	"github.com/qantik/evo/backend/crypto/suites"
+	"github.com/qantik/evo/backend/crypto/transcript"
+)
+
+// Published output of a single mix server, column by column.
+type Hop struct {
+	X, Y    [][]kyber.Point
+	Proof   []byte
+	Timings Timings
+}
+
+// Time a mix server spent re-encrypting and permuting the pairs, generating
+// the proof and within the latter in the embedded simple k-shuffle. Offline
+// is the time spent on a shuffle kit ahead of the pairs, if one was used.
+type Timings struct {
+	Offline time.Duration
+	Shuffle time.Duration
+	Prove   time.Duration
+	Simple  time.Duration
+}
+
+// Accumulate the timings of all hops.
+func (t *Timings) Add(u Timings) {
+	t.Offline += u.Offline
+	t.Shuffle += u.Shuffle
+	t.Prove += u.Prove
+	t.Simple += u.Simple
+}
+
+// Mix cascade with its input and the published outputs of all hops. The
+// input pairs are given column by column, X[j][i] is the j-th pair of the
+// i-th row.
+type Cascade struct {
+	suite suites.Suite
+	g, h  kyber.Point
+	X, Y  [][]kyber.Point
+	Hops  []*Hop
+
+	// Election identifier the proofs of all hops are bound to.
+	session []byte
+
+	// Number of goroutines hops are proven and verified with.
+	workers int
+
+	// Fixed-base tables of g and h hops are shuffled and proven with.
+	bases *elgamal.Bases
+
+	// Prepare a shuffle kit before every hop.
+	offline bool
+}
+
+// Error of a cascade verification pointing at the offending hop.
+type HopError struct {
+	Hop int
+	Err error
+}
+
+func (e *HopError) Error() string {
+	return fmt.Sprintf("mix hop %d: %v", e.Hop, e.Err)
+}
+
+// Shuffle the columns (X, Y) under the public key h as the given hop of the
+// election session and prove its correctness with the given number of
+// goroutines.
+func Mix(suite suites.Suite, g, h kyber.Point, X, Y [][]kyber.Point,
+	session []byte, hop, workers int, stream cipher.Stream) (*Hop, error) {
+
+	c := &Cascade{suite: suite, g: g, h: h, session: session, workers: workers}
+	return c.mix(hop, X, Y, stream)
+}
+
+func (c *Cascade) mix(index int, X, Y [][]kyber.Point, stream cipher.Stream) (
+	*Hop, error) {
+
+	if len(X) == 0 {
+		return nil, elgamal.ErrMismatchedLength
+	}
+	ps, err := new(neff.PairShuffle).InitWide(c.suite, len(X[0]), len(X))
+	if err != nil {
+		return nil, err
+	}
+	ps.SetWorkers(c.workers).SetBases(c.bases)
+
+	hop := new(Hop)
+	var kit *neff.Kit
+	if c.offline {
+		start := time.Now()
+		kit = ps.Precompute(c.g, c.h, stream)
+		hop.Timings.Offline = time.Since(start)
+	}
+
+	var prover proof.Prover
+	start := time.Now()
+	if kit != nil {
+		hop.X, hop.Y, prover, err = ps.ShuffleKitWide(kit, X, Y)
+	} else {
+		hop.X, hop.Y, prover, err = ps.ShuffleWide(c.g, c.h, X, Y, stream)
+	}
+	hop.Timings.Shuffle = time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := c.transcript(index, X, Y, hop)
+	start = time.Now()
+	err = sp.Prove(prover)
+	hop.Timings.Prove = time.Since(start)
+	hop.Timings.Simple = ps.SimpleTime()
+	if err != nil {
+		return nil, err
+	}
+
+	hop.Proof = sp.Proof
+	return hop, nil
+}
+
+// Create a cascade for the input pairs (X, Y) without any hops.
+func New(suite suites.Suite, g, h kyber.Point, X, Y []kyber.Point) *Cascade {
+	return NewWide(suite, g, h, [][]kyber.Point{X}, [][]kyber.Point{Y})
+}
+
+// Create a cascade for wide input ciphertexts given column by column.
+func NewWide(suite suites.Suite, g, h kyber.Point, X, Y [][]kyber.Point) *Cascade {
+	return &Cascade{suite: suite, g: g, h: h, X: X, Y: Y}
+}
+
+// Bind the proofs of all hops to the election identifier.
+func (c *Cascade) SetSession(session []byte) *Cascade {
+	c.session = session
+	return c
+}
+
+// Prove and verify the hops with the given number of goroutines, at most one
+// runs serially.
+func (c *Cascade) SetWorkers(workers int) *Cascade {
+	c.workers = workers
+	return c
+}
+
+// Shuffle and prove the hops with the fixed-base tables of g and h.
+func (c *Cascade) SetBases(bases *elgamal.Bases) *Cascade {
+	c.bases = bases
+	return c
+}
+
+// Prepare a shuffle kit for every hop before it mixes, as a mix server does
+// while it waits for its input.
+func (c *Cascade) SetOffline(offline bool) *Cascade {
+	c.offline = offline
+	return c
+}
+
+// Run n mix servers one after the other.
+func Run(suite suites.Suite, g, h kyber.Point, X, Y []kyber.Point, n int,
+	stream cipher.Stream) (*Cascade, error) {
+
+	cascade := New(suite, g, h, X, Y)
+	for i := 0; i < n; i++ {
+		if err := cascade.Mix(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	return cascade, nil
+}
+
+// Sum of the timings of all hops.
+func (c *Cascade) Timings() Timings {
+	var t Timings
+	for _, hop := range c.Hops {
+		t.Add(hop.Timings)
+	}
+	return t
+}
+
+// Columns of the output of the last hop or of the cascade input if there
+// are no hops yet.
+func (c *Cascade) Output() (X, Y [][]kyber.Point) {
+	if len(c.Hops) == 0 {
+		return c.X, c.Y
+	}
+	hop := c.Hops[len(c.Hops)-1]
+	return hop.X, hop.Y
+}
+
+// Append another mix server shuffling the current output.
+func (c *Cascade) Mix(stream cipher.Stream) error {
+	X, Y := c.Output()
+	hop, err := c.mix(len(c.Hops), X, Y, stream)
+	if err != nil {
+		return err
+	}
+
+	c.Hops = append(c.Hops, hop)
+	return nil
+}
+
+// Replay the shuffle verification of every hop. Returns a *HopError for the
+// first hop whose proof does not verify.
+func (c *Cascade) Verify() error {
+	X, Y := c.X, c.Y
+	for i, hop := range c.Hops {
+		verifier, err := neff.VerifierWide(c.suite, c.g, c.h, X, Y, hop.X, hop.Y,
+			c.workers)
+		if err != nil {
+			return &HopError{Hop: i, Err: err}
+		}
+		name, err := c.transcript(i, X, Y, hop).Domain()
+		if err != nil {
+			return &HopError{Hop: i, Err: err}
+		}
+		if err := proof.HashVerify(c.suite, name, verifier, hop.Proof); err != nil {
+			return &HopError{Hop: i, Err: err}
+		}
+
+		X, Y = hop.X, hop.Y
+	}
+
+	return nil
+}
+
+// Standalone shuffle proof transcripts of all hops.
+func (c *Cascade) Transcripts() []*transcript.ShuffleProof {
+	transcripts := make([]*transcript.ShuffleProof, len(c.Hops))
+
+	X, Y := c.X, c.Y
+	for i, hop := range c.Hops {
+		transcripts[i] = c.transcript(i, X, Y, hop)
+		X, Y = hop.X, hop.Y
+	}
+
+	return transcripts
+}
+
+// Transcript of the hop with the given index shuffling (X, Y).
+func (c *Cascade) transcript(index int, X, Y [][]kyber.Point,
+	hop *Hop) *transcript.ShuffleProof {
+
+	sp := &transcript.ShuffleProof{
+		Suite:    c.suite,
+		Protocol: transcript.Neff,
+		Session:  c.session,
+		Hop:      index,
+		G:        c.g,
+		H:        c.h,
+		Proof:    hop.Proof,
+	}
+	sp.SetColumns(X, Y, hop.X, hop.Y)
+	return sp
+}
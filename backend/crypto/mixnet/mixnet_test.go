@@ -0,0 +1,206 @@
+package mixnet
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/nist"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func setup(k int) (suites.Suite, cipher.Stream, *elgamal.KeyPair, []kyber.Point, []kyber.Point) {
+	suite := nist.NewBlakeSHA256P256()
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+
+	X := make([]kyber.Point, k)
+	Y := make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		X[i], Y[i], _ = elgamal.Encrypt(suite, key.Public, []byte(fmt.Sprintf("vote#%d", i)), stream)
+	}
+
+	return suite, stream, key, X, Y
+}
+
+func TestCascade(t *testing.T) {
+	suite, stream, key, X, Y := setup(8)
+	cascade, err := Run(suite, suite.Point().Base(), key.Public, X, Y, 3, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cascade.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cascade.SetWorkers(4).Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output still decrypts to the original votes.
+	votes := make(map[string]bool)
+	Xbar, Ybar := cascade.Output()
+	for i := range Xbar[0] {
+		m, err := elgamal.Decrypt(suite, key.Secret, Xbar[0][i], Ybar[0][i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		votes[string(m)] = true
+	}
+	for i := 0; i < 8; i++ {
+		if !votes[fmt.Sprintf("vote#%d", i)] {
+			t.Fatalf("vote#%d lost in cascade", i)
+		}
+	}
+}
+
+func TestOffline(t *testing.T) {
+	suite, stream, key, X, Y := setup(8)
+	g := suite.Point().Base()
+	cascade := New(suite, g, key.Public, X, Y).
+		SetBases(elgamal.NewBases(suite, g, key.Public)).SetOffline(true)
+	for i := 0; i < 2; i++ {
+		if err := cascade.Mix(stream); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cascade.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if cascade.Timings().Offline <= 0 {
+		t.Fatal("offline phase not timed")
+	}
+
+	Xbar, Ybar := cascade.Output()
+	votes := make(map[string]bool)
+	for i := range Xbar[0] {
+		m, err := elgamal.Decrypt(suite, key.Secret, Xbar[0][i], Ybar[0][i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		votes[string(m)] = true
+	}
+	if len(votes) != 8 {
+		t.Fatalf("%d distinct votes after the cascade", len(votes))
+	}
+}
+
+// Hop proofs are bound to the session and their position in the cascade.
+func TestSession(t *testing.T) {
+	suite, stream, key, X, Y := setup(4)
+	cascade := New(suite, suite.Point().Base(), key.Public, X, Y).SetSession([]byte("election"))
+	for i := 0; i < 2; i++ {
+		if err := cascade.Mix(stream); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cascade.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	for _, sp := range cascade.Transcripts() {
+		if err := sp.Verify(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cascade.SetSession([]byte("other")).Verify() == nil {
+		t.Fatal("cascade verified under another session")
+	}
+	cascade.SetSession([]byte("election"))
+	sp := cascade.Transcripts()[1]
+	sp.Hop = 0
+	if sp.Verify() == nil {
+		t.Fatal("hop verified at another position")
+	}
+}
+
+func TestCheatingHop(t *testing.T) {
+	suite, stream, key, X, Y := setup(8)
+	cascade, err := Run(suite, suite.Point().Base(), key.Public, X, Y, 3, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second mix server replaces a ballot after proving.
+	hop := cascade.Hops[1]
+	hop.X[0][0], hop.Y[0][0], _ = elgamal.Encrypt(suite, key.Public, []byte("forged"), stream)
+
+	err = cascade.Verify()
+	if e, ok := err.(*HopError); !ok || e.Hop != 1 {
+		t.Fatalf("expected failure at hop 1, got %v", err)
+	}
+}
+
+// Rows of several pairs stay together through the cascade and its
+// transcripts record their width.
+func TestWide(t *testing.T) {
+	suite, stream, key, _, _ := setup(0)
+	g := suite.Point().Base()
+
+	k, width := 6, 3
+	X := make([][]kyber.Point, width)
+	Y := make([][]kyber.Point, width)
+	for j := range X {
+		X[j] = make([]kyber.Point, k)
+		Y[j] = make([]kyber.Point, k)
+	}
+	for i := 0; i < k; i++ {
+		payload := []byte(fmt.Sprintf("a rather long write-in vote #%d", i))
+		alpha, beta, err := elgamal.EncryptRow(suite, key.Public, payload, width, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range alpha {
+			X[j][i], Y[j][i] = alpha[j], beta[j]
+		}
+	}
+
+	for _, offline := range []bool{false, true} {
+		cascade := NewWide(suite, g, key.Public, X, Y).SetSession([]byte("election")).
+			SetBases(elgamal.NewBases(suite, g, key.Public)).SetOffline(offline)
+		for i := 0; i < 2; i++ {
+			if err := cascade.Mix(stream); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cascade.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		for _, sp := range cascade.Transcripts() {
+			if err := sp.Verify(); err != nil || sp.Width != width {
+				t.Fatalf("transcript of width %d: %v", sp.Width, err)
+			}
+		}
+
+		Xbar, Ybar := cascade.Output()
+		votes := make(map[string]bool)
+		for i := 0; i < k; i++ {
+			alpha := make([]kyber.Point, width)
+			beta := make([]kyber.Point, width)
+			for j := range alpha {
+				alpha[j], beta[j] = Xbar[j][i], Ybar[j][i]
+			}
+			m, err := elgamal.DecryptRow(suite, key.Secret, alpha, beta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			votes[string(m)] = true
+		}
+		for i := 0; i < k; i++ {
+			if !votes[fmt.Sprintf("a rather long write-in vote #%d", i)] {
+				t.Fatalf("row %d lost in cascade", i)
+			}
+		}
+
+		// A hop that swaps the pairs of one column between two rows.
+		hop := cascade.Hops[1]
+		hop.X[2][0], hop.X[2][1] = hop.X[2][1], hop.X[2][0]
+		hop.Y[2][0], hop.Y[2][1] = hop.Y[2][1], hop.Y[2][0]
+		err := cascade.Verify()
+		if e, ok := err.(*HopError); !ok || e.Hop != 1 {
+			t.Fatalf("expected failure at hop 1, got %v", err)
+		}
+	}
+}
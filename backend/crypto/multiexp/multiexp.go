@@ -0,0 +1,311 @@
+/*
+Package multiexp computes sums of scalar multiples s[0]*P[0] + ... + s[n-1]*P[n-1]
+faster than n separate multiplications. Straus' method shares the doublings
+among all points and suits few points, Pippenger's bucket method trades the
+per-point tables for buckets and wins for many points.
+
+Both only use point additions. How much cheaper an addition is than a scalar
+multiplication differs widely between groups: the NIST curves of the standard
+library convert to affine coordinates on every addition, while the Ed25519 and
+residue groups add almost for free. The ratio is fixed per group, so that Mul
+switches to a multi-exponentiation at the same number of points on every
+machine, and falls back to separate multiplications where neither method pays
+off. That is always the case for the NIST curves, P256 the default suite
+included, which therefore never take the multi-exponentiation path.
+
+A Table serves the other common case, many multiplications of one fixed point
+by different scalars.
+
+Mul selects table entries and buckets by the digits of the scalars, so its
+memory accesses and running time depend on them, and it is meant for public
+scalars only. MulSecret serves secret ones. Table lookups read every entry
+of a window and add for every window, zero digits included, so they suit
+secret scalars as far as the arithmetic of the group itself does not leak
+them.
+*/
+package multiexp
+
+import (
+	"crypto/subtle"
+	"sync"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// Properties of a group determined on first use.
+type profile struct {
+	ratio  float64 // cost of a scalar multiplication in additions
+	bits   int     // bit length of encoded scalars
+	little bool    // scalars are encoded little-endian
+}
+
+// Cost of a scalar multiplication in additions in the groups of package
+// suites, measured once on amd64. Other groups multiply separately.
+var ratios = map[string]float64{
+	"Ed25519":     240,
+	"P256":        10,
+	"P384":        20,
+	"P521":        24,
+	"Residue512":  180,
+	"Residue2048": 980,
+}
+
+var profiles sync.Map
+
+func lookup(group kyber.Group) *profile {
+	if p, ok := profiles.Load(group.String()); ok {
+		return p.(*profile)
+	}
+
+	one, _ := group.Scalar().One().MarshalBinary()
+	p := &profile{
+		ratio:  ratios[group.String()],
+		bits:   8 * len(one),
+		little: one[0] == 1 && len(one) > 1,
+	}
+	profiles.Store(group.String(), p)
+
+	return p
+}
+
+// Big-endian encodings of the scalars.
+func encode(prof *profile, s []kyber.Scalar) [][]byte {
+	buf := make([][]byte, len(s))
+	for i := range s {
+		b, err := s[i].MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		if prof.little {
+			for l, r := 0, len(b)-1; l < r; l, r = l+1, r-1 {
+				b[l], b[r] = b[r], b[l]
+			}
+		}
+		buf[i] = b
+	}
+	return buf
+}
+
+// Value of the c bits of the big-endian number b starting at bit lo.
+func digit(b []byte, lo, c int) int {
+	d := 0
+	for i := lo + c - 1; i >= lo; i-- {
+		d <<= 1
+		if n := len(b) - 1 - i/8; n >= 0 {
+			d |= int(b[n]>>uint(i%8)) & 1
+		}
+	}
+	return d
+}
+
+// Multi-scalar multiplication of points with big-endian encoded scalars of
+// the given bit length using windows of c bits.
+type method func(group kyber.Group, bits int, s [][]byte, P []kyber.Point,
+	c int) kyber.Point
+
+// Method and window size of the cheapest evaluation of n products, with
+// costs counted in point additions. Returns a nil method if separate
+// multiplications are cheaper.
+func plan(prof *profile, n int) (m method, window int) {
+	best := float64(n) * (prof.ratio + 1)
+	for c := 1; c <= 16; c++ {
+		windows := float64((prof.bits + c - 1) / c)
+		// Straus: tables of 2^c multiples per point, b doublings and one
+		// addition per point and window.
+		if c <= 8 {
+			cost := float64(n)*float64(int(1)<<uint(c)) + float64(prof.bits) + windows*float64(n)
+			if cost < best {
+				best, m, window = cost, straus, c
+			}
+		}
+		// Pippenger: per window one addition per point, two per bucket and c
+		// doublings.
+		cost := windows * (float64(n) + 2*float64(int(1)<<uint(c)) + float64(c))
+		if cost < best {
+			best, m, window = cost, pippenger, c
+		}
+	}
+	return
+}
+
+// Return the sum of s[i]*P[i] for public scalars. Both slices must have the
+// same length.
+func Mul(group kyber.Group, s []kyber.Scalar, P []kyber.Point) kyber.Point {
+	if len(s) != len(P) {
+		panic("multiexp: mismatched vector lengths")
+	}
+
+	prof := lookup(group)
+	m, c := plan(prof, len(P))
+	if m == nil {
+		return naive(group, s, P)
+	}
+	return m(group, prof.bits, encode(prof, s), P, c)
+}
+
+// Return the sum of s[i]*P[i] for secret scalars, such as the blinding
+// factors of a prover, with a separate multiplication of the group for every
+// point. Both slices must have the same length.
+func MulSecret(group kyber.Group, s []kyber.Scalar, P []kyber.Point) kyber.Point {
+	if len(s) != len(P) {
+		panic("multiexp: mismatched vector lengths")
+	}
+	return naive(group, s, P)
+}
+
+func naive(group kyber.Group, s []kyber.Scalar, P []kyber.Point) kyber.Point {
+	R := group.Point().Null()
+	Q := group.Point()
+	for i := range P {
+		R.Add(R, Q.Mul(s[i], P[i]))
+	}
+	return R
+}
+
+// Straus' interleaved method with a table of the multiples 0..2^c-1 of every
+// point.
+func straus(group kyber.Group, bits int, s [][]byte, P []kyber.Point,
+	c int) kyber.Point {
+
+	tables := make([][]kyber.Point, len(P))
+	for i := range P {
+		t := make([]kyber.Point, 1<<uint(c))
+		t[0] = group.Point().Null()
+		for j := 1; j < len(t); j++ {
+			t[j] = group.Point().Add(t[j-1], P[i])
+		}
+		tables[i] = t
+	}
+
+	R := group.Point().Null()
+	for lo := (bits - 1) / c * c; lo >= 0; lo -= c {
+		for j := 0; j < c; j++ {
+			R.Add(R, R)
+		}
+		for i := range P {
+			if d := digit(s[i], lo, c); d != 0 {
+				R.Add(R, tables[i][d])
+			}
+		}
+	}
+	return R
+}
+
+// Pippenger's bucket method: within every window the points are sorted into
+// buckets by their digit and the buckets are summed with running sums.
+func pippenger(group kyber.Group, bits int, s [][]byte, P []kyber.Point,
+	c int) kyber.Point {
+
+	buckets := make([]kyber.Point, 1<<uint(c))
+	for j := range buckets {
+		buckets[j] = group.Point()
+	}
+	used := make([]bool, len(buckets))
+
+	R := group.Point().Null()
+	sum := group.Point()
+	acc := group.Point()
+	for lo := (bits - 1) / c * c; lo >= 0; lo -= c {
+		for j := 0; j < c; j++ {
+			R.Add(R, R)
+		}
+
+		for j := range used {
+			used[j] = false
+		}
+		for i := range P {
+			d := digit(s[i], lo, c)
+			if d == 0 {
+				continue
+			}
+			if used[d] {
+				buckets[d].Add(buckets[d], P[i])
+			} else {
+				buckets[d].Set(P[i])
+				used[d] = true
+			}
+		}
+
+		// sum_d d*bucket[d] as the sum of the running sums from the top.
+		sum.Null()
+		acc.Null()
+		for d := len(buckets) - 1; d > 0; d-- {
+			if used[d] {
+				sum.Add(sum, buckets[d])
+			}
+			acc.Add(acc, sum)
+		}
+		R.Add(R, acc)
+	}
+	return R
+}
+
+// Fixed point with precomputed multiples d*2^(jc)*P for all c-bit digits d
+// and windows j, so that a multiplication takes one addition per window and
+// no doublings. Tables are read-only once built and safe for concurrent use.
+type Table struct {
+	group kyber.Group
+	P     kyber.Point
+	base  bool
+	prof  *profile
+	rows  [][]kyber.Point
+}
+
+// Bits per window of a table. Every lookup scans the whole row of its
+// window, which keeps windows narrow.
+const tableWindow = 4
+
+// Build the table of P. In groups where additions are not much cheaper than
+// multiplications no multiples are stored and Mul multiplies directly.
+func NewTable(group kyber.Group, P kyber.Point) *Table {
+	t := &Table{
+		group: group,
+		P:     P,
+		base:  P.Equal(group.Point().Base()),
+		prof:  lookup(group),
+	}
+
+	// A lookup costs an addition and 2^c copies per window, about two
+	// additions all told.
+	windows := (t.prof.bits + tableWindow - 1) / tableWindow
+	if float64(windows) > t.prof.ratio/2 {
+		return t
+	}
+
+	t.rows = make([][]kyber.Point, windows)
+	Q := group.Point().Set(P)
+	for j := range t.rows {
+		row := make([]kyber.Point, 1<<tableWindow)
+		row[0] = group.Point().Null()
+		for d := 1; d < len(row); d++ {
+			row[d] = group.Point().Add(row[d-1], Q)
+		}
+		Q.Add(row[len(row)-1], Q)
+		t.rows[j] = row
+	}
+
+	return t
+}
+
+// Return s*P as a new point. Every window copies all entries of its row,
+// keeping the one of its digit, and adds it even if it is zero.
+func (t *Table) Mul(s kyber.Scalar) kyber.Point {
+	if t.rows == nil {
+		if t.base {
+			return t.group.Point().Mul(s, nil)
+		}
+		return t.group.Point().Mul(s, t.P)
+	}
+
+	b := encode(t.prof, []kyber.Scalar{s})[0]
+	R := t.group.Point().Null()
+	sel := [2]kyber.Point{t.group.Point(), t.group.Point()}
+	for j, row := range t.rows {
+		d := int32(digit(b, j*tableWindow, tableWindow))
+		for e, Q := range row {
+			sel[subtle.ConstantTimeEq(int32(e), d)].Set(Q)
+		}
+		R.Add(R, sel[1])
+	}
+	return R
+}
@@ -0,0 +1,173 @@
+package multiexp
+
+import (
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func vectors(suite suites.Suite, n int) ([]kyber.Scalar, []kyber.Point) {
+	stream := suite.RandomStream()
+	s := make([]kyber.Scalar, n)
+	P := make([]kyber.Point, n)
+	for i := range s {
+		s[i] = suite.Scalar().Pick(stream)
+		P[i] = suite.Point().Pick(stream)
+	}
+	// Include the extreme digits.
+	if n > 2 {
+		s[0].Zero()
+		s[1].One()
+		s[2].SetInt64(-1)
+	}
+	return s, P
+}
+
+func TestMul(t *testing.T) {
+	for _, name := range suites.Names() {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			for _, n := range []int{0, 1, 2, 40} {
+				s, P := vectors(suite, n)
+				if !Mul(suite, s, P).Equal(naive(suite, s, P)) {
+					t.Fatalf("wrong sum of %d products", n)
+				}
+				if !MulSecret(suite, s, P).Equal(naive(suite, s, P)) {
+					t.Fatalf("wrong sum of %d secret products", n)
+				}
+			}
+		})
+	}
+}
+
+// Run both methods with windows that do and do not divide the scalar length
+// in groups with big and little-endian scalars.
+func TestMethods(t *testing.T) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			prof := lookup(suite)
+			for _, n := range []int{0, 1, 7} {
+				s, P := vectors(suite, n)
+				want := naive(suite, s, P)
+				buf := encode(prof, s)
+				for _, c := range []int{1, 3, 8} {
+					if !straus(suite, prof.bits, buf, P, c).Equal(want) {
+						t.Fatalf("straus wrong for %d points and window %d", n, c)
+					}
+					if !pippenger(suite, prof.bits, buf, P, c).Equal(want) {
+						t.Fatalf("pippenger wrong for %d points and window %d", n, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	cheap := &profile{ratio: 200, bits: 256}
+	if m, _ := plan(cheap, 1); m != nil {
+		t.Fatal("single product not computed directly")
+	}
+	if m, c := plan(cheap, 4000); m == nil || c < 8 {
+		t.Fatalf("window %d for 4000 products", c)
+	}
+	if m, _ := plan(&profile{ratio: 10, bits: 256}, 4000); m != nil {
+		t.Fatal("multi-exponentiation with expensive additions")
+	}
+}
+
+// Mul switches to a multi-exponentiation at a fixed number of points per
+// group, never on the NIST curves and in unknown groups.
+func TestThreshold(t *testing.T) {
+	threshold := func(group kyber.Group) int {
+		prof := lookup(group)
+		for n := 1; n <= 10000; n++ {
+			if m, _ := plan(prof, n); m != nil {
+				for more := n; more <= 10000; more += 100 {
+					if m, _ := plan(prof, more); m == nil {
+						t.Fatalf("%s falls back at %d points", group, more)
+					}
+				}
+				return n
+			}
+		}
+		return 0
+	}
+	for name, want := range map[string]bool{"Ed25519": true, "Residue512": true, "P256": false} {
+		suite, _ := suites.Lookup(name)
+		if n := threshold(suite); (n > 0) != want || n > 100 {
+			t.Fatalf("%s threshold %d", name, n)
+		}
+	}
+	suite, _ := suites.Lookup("Ed25519")
+	if n := threshold(renamed{suite}); n != 0 {
+		t.Fatalf("unknown group threshold %d", n)
+	}
+}
+
+type renamed struct{ kyber.Group }
+
+func (renamed) String() string { return "renamed" }
+
+// Compare separate multiplications against Mul for thousands of points.
+func BenchmarkMul(b *testing.B) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		for _, n := range []int{1000, 4000} {
+			suite, _ := suites.Lookup(name)
+			s, P := vectors(suite, n)
+			lookup(suite)
+			b.Run(fmt.Sprintf("%s/n=%d/naive", name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					naive(suite, s, P)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/n=%d/multiexp", name, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					Mul(suite, s, P)
+				}
+			})
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			suite = suites.Seeded(suite, []byte(t.Name()))
+			s, P := vectors(suite, 5)
+			for _, Q := range []kyber.Point{suite.Point().Base(), P[0]} {
+				table := NewTable(suite, Q)
+				for i := range s {
+					if !table.Mul(s[i]).Equal(suite.Point().Mul(s[i], Q)) {
+						t.Fatalf("wrong multiple %d", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTable(b *testing.B) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		suite, _ := suites.Lookup(name)
+		s, P := vectors(suite, 1)
+		table := NewTable(suite, P[0])
+		b.Run(name+"/direct", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				suite.Point().Mul(s[0], P[0])
+			}
+		})
+		b.Run(name+"/table", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				table.Mul(s[0])
+			}
+		})
+	}
+}
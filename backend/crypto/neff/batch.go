@@ -0,0 +1,217 @@
+package neff
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/multiexp"
+)
+
+// Random linear combination of the group equations of one or more proofs.
+// Every equation sum s_i P_i = 0 is scaled by a fresh random weight and all
+// of them are checked at once with a single multi-exponentiation. In a group
+// of prime order q a false equation survives with probability 1/q.
+//
+// In a group with a cofactor an equation may be off by a point of small
+// order, which vanishes whenever the weight is a multiple of that order. So
+// there every point of the statements and the proofs has to lie in the
+// subgroup of order q, a point outside of it fails the batch. The points the
+// verifier derives from them follow. The checks cost a multiplication per
+// point, on Ed25519 more than the batch saves over individual verification.
+type batch struct {
+	grp  kyber.Group
+	rand cipher.Stream
+	s    []kyber.Scalar
+	P    []kyber.Point
+	sub  bool // check subgroup membership
+	bad  bool // a point outside of the subgroup entered
+}
+
+// Groups with a cofactor.
+var cofactors = map[string]bool{"Ed25519": true}
+
+func newBatch(grp kyber.Group, rand cipher.Stream) *batch {
+	return &batch{grp: grp, rand: rand, sub: cofactors[grp.String()]}
+}
+
+// Random weight of the next equation.
+func (b *batch) weight() kyber.Scalar {
+	return b.grp.Scalar().Pick(b.rand)
+}
+
+func (b *batch) term(s kyber.Scalar, P kyber.Point) {
+	b.s = append(b.s, s)
+	b.P = append(b.P, P)
+}
+
+// Fail the batch unless every point lies in the subgroup of order q, that is
+// (q-1)P = -P.
+func (b *batch) check(P ...kyber.Point) {
+	if !b.sub || b.bad {
+		return
+	}
+	m := b.grp.Scalar().SetInt64(-1)
+	Q := b.grp.Point()
+	if v, ok := Q.(kyber.AllowsVarTime); ok {
+		v.AllowVarTime(true) // the points are public
+	}
+	for _, P := range P {
+		if !Q.Mul(m, P).Equal(b.grp.Point().Neg(P)) {
+			b.bad = true
+			return
+		}
+	}
+}
+
+func (b *batch) holds() bool {
+	return !b.bad && multiexp.Mul(b.grp, b.s, b.P).Equal(b.grp.Point().Null())
+}
+
+// Statement and proof of a single Neff shuffle of (X, Y) into (Xbar, Ybar)
+// under generator G and public key H, made with HashProve under Name. The
+// vectors of a wide shuffle hold Width columns one after the other, see
+// elgamal.Columns, a Width of zero denotes a single column.
+type Instance struct {
+	Name       string
+	G, H       kyber.Point
+	X, Y       []kyber.Point
+	Xbar, Ybar []kyber.Point
+	Proof      []byte
+	Width      int
+}
+
+// Columns of the statement vectors.
+func (in *Instance) columns() (X, Y, Xbar, Ybar [][]kyber.Point, err error) {
+	width := in.Width
+	if width == 0 {
+		width = 1
+	}
+	V := make([][][]kyber.Point, 4)
+	for i, v := range [][]kyber.Point{in.X, in.Y, in.Xbar, in.Ybar} {
+		if V[i], err = elgamal.Columns(v, width); err != nil {
+			return
+		}
+	}
+	return V[0], V[1], V[2], V[3], nil
+}
+
+// Error of a batch verification. Errs holds the outcome of the individual
+// verification of every proof, nil for those that are valid.
+type BatchError struct {
+	Errs []error
+}
+
+func (e *BatchError) Error() string {
+	n, first := 0, -1
+	for i, err := range e.Errs {
+		if err != nil {
+			n++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return fmt.Sprintf("batch of %d shuffle proofs invalid", len(e.Errs))
+	}
+	return fmt.Sprintf("%d of %d shuffle proofs invalid, proof %d: %v", n,
+		len(e.Errs), first, e.Errs[first])
+}
+
+// Verify many Neff shuffle proofs. The Fiat-Shamir challenges of every proof
+// are replayed individually, the group equations of all proofs are checked
+// with one random linear combination. If that fails, the proofs are verified
+// one by one and a *BatchError pinpoints the invalid ones. The result is
+// the one of the individual verification, so if every proof turns out valid
+// there, BatchVerify returns nil. The weights are drawn from rand, which has
+// to be unpredictable to the provers.
+func BatchVerify(suite proof.Suite, instances []Instance, rand cipher.Stream) error {
+	errs := make([]error, len(instances))
+	all := newBatch(suite, rand)
+	failed := false
+	for i, in := range instances {
+		b := newBatch(suite, rand)
+		errs[i] = deferred(suite, in, b)
+		if errs[i] != nil || b.bad {
+			failed = true
+			continue
+		}
+		all.s = append(all.s, b.s...)
+		all.P = append(all.P, b.P...)
+	}
+	if !failed && all.holds() {
+		return nil
+	}
+
+	for i, in := range instances {
+		if errs[i] != nil {
+			continue
+		}
+		errs[i] = verifyInstance(suite, in)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return &BatchError{errs}
+		}
+	}
+	return nil
+}
+
+func verifyInstance(suite proof.Suite, in Instance) error {
+	X, Y, Xbar, Ybar, err := in.columns()
+	if err != nil {
+		return err
+	}
+	verifier, err := VerifierWide(suite, in.G, in.H, X, Y, Xbar, Ybar, 1)
+	if err != nil {
+		return err
+	}
+	return proof.HashVerify(suite, in.Name, verifier, in.Proof)
+}
+
+// Replay the proof of a single instance and add its group equations to b
+// instead of checking them.
+func deferred(suite proof.Suite, in Instance, b *batch) error {
+	X, Y, Xbar, Ybar, err := in.columns()
+	if err != nil {
+		return err
+	}
+	if len(X) == 0 || !fits(len(X[0]), len(X), X, Y, Xbar, Ybar) {
+		return elgamal.ErrMismatchedLength
+	}
+	b.check(in.G, in.H)
+	for _, v := range [][]kyber.Point{in.X, in.Y, in.Xbar, in.Ybar} {
+		b.check(v...)
+	}
+
+	ps := PairShuffle{}
+	if _, err := ps.InitWide(suite, len(X[0]), len(X)); err != nil {
+		return err
+	}
+	ps.batch = b
+	ps.pv6.batch = b
+
+	verifier := func(ctx proof.VerifierContext) error {
+		return ps.VerifyWide(in.G, in.H, X, Y, Xbar, Ybar, ctx)
+	}
+	if err := proof.HashVerify(suite, in.Name, verifier, in.Proof); err != nil {
+		return err
+	}
+	b.check(ps.sent()...)
+	return nil
+}
+
+// Points of the proof.
+func (ps *PairShuffle) sent() []kyber.Point {
+	p1, ss := &ps.p1, &ps.pv6
+	P := []kyber.Point{p1.Gamma}
+	for _, v := range [][]kyber.Point{p1.A, p1.C, p1.U, p1.W, p1.Lambda1,
+		p1.Lambda2, ps.p3.D, ss.p0.X, ss.p0.Y, ss.p2.Theta} {
+		P = append(P, v...)
+	}
+	return P
+}
@@ -3,38 +3,42 @@ package neff
 import (
 	"crypto/cipher"
 	"errors"
+	"time"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
 
 	"github.com/qantik/evo/backend/crypto/elgamal"
-	"gopkg.in/dedis/crypto.v0/abstract"
-	"gopkg.in/dedis/crypto.v0/proof"
+	"github.com/qantik/evo/backend/crypto/multiexp"
+	"github.com/qantik/evo/backend/crypto/permutation"
 )
 
-// P (Prover) step 1: public commitments
+// P (Prover) step 1: public commitments, Lambda1 and Lambda2 per column
 type ega1 struct {
-	Gamma            abstract.Point
-	A, C, U, W       []abstract.Point
-	Lambda1, Lambda2 abstract.Point
+	Gamma            kyber.Point
+	A, C, U, W       []kyber.Point
+	Lambda1, Lambda2 []kyber.Point
 }
 
 // V (Verifier) step 2: random challenge t
 type ega2 struct {
-	Zrho []abstract.Scalar
+	Zrho []kyber.Scalar
 }
 
 // P step 3: Theta vectors
 type ega3 struct {
-	D []abstract.Point
+	D []kyber.Point
 }
 
 // V step 4: random challenge c
 type ega4 struct {
-	Zlambda abstract.Scalar
+	Zlambda kyber.Scalar
 }
 
-// P step 5: alpha vector
+// P step 5: alpha vector and tau per column
 type ega5 struct {
-	Zsigma []abstract.Scalar
-	Ztau   abstract.Scalar
+	Zsigma []kyber.Scalar
+	Ztau   []kyber.Scalar
 }
 
 // P and V, step 6: simple k-shuffle proof
@@ -43,7 +47,7 @@ type ega6 struct {
 }
 
 type PairShuffle struct {
-	grp abstract.Group
+	grp kyber.Group
 	k   int
 	p1  ega1
 	v2  ega2
@@ -51,76 +55,259 @@ type PairShuffle struct {
 	v4  ega4
 	p5  ega5
 	pv6 SimpleShuffle
+
+	// Number of pairs per row, see InitWide.
+	width int
+
+	// Number of goroutines the O(k) loops are split across.
+	workers int
+
+	// If set, Verify adds its group equations to the batch instead of
+	// checking them.
+	batch *batch
+
+	// Fixed-base tables Shuffle and Prove use if they belong to g and h.
+	bases *elgamal.Bases
+
+	// Duration of the embedded simple k-shuffle in the last Prove.
+	simple time.Duration
+}
+
+func (ps *PairShuffle) Init(grp kyber.Group, k int) (*PairShuffle, error) {
+	return ps.InitWide(grp, k, 1)
 }
 
-func (ps *PairShuffle) Init(grp abstract.Group, k int) *PairShuffle {
+// Prepare a shuffle of k rows of width pairs each, see elgamal.PermuteWide.
+// The permutation commitments and the simple k-shuffle are shared by all
+// columns, every further column only adds Lambda1, Lambda2 and tau to the
+// proof. A proof of width one is the same as that of a pair shuffle.
+func (ps *PairShuffle) InitWide(grp kyber.Group, k, width int) (*PairShuffle,
+	error) {
+
 	if k <= 1 {
-		panic("can't shuffle permutation of size <= 1")
+		return nil, elgamal.ErrTooFewCiphertexts
+	}
+	if width < 1 {
+		return nil, elgamal.ErrMismatchedLength
 	}
 
 	ps.grp = grp
 	ps.k = k
-	ps.p1.A = make([]abstract.Point, k)
-	ps.p1.C = make([]abstract.Point, k)
-	ps.p1.U = make([]abstract.Point, k)
-	ps.p1.W = make([]abstract.Point, k)
-	ps.v2.Zrho = make([]abstract.Scalar, k)
-	ps.p3.D = make([]abstract.Point, k)
-	ps.p5.Zsigma = make([]abstract.Scalar, k)
+	ps.width = width
+	ps.p1.A = make([]kyber.Point, k)
+	ps.p1.C = make([]kyber.Point, k)
+	ps.p1.U = make([]kyber.Point, k)
+	ps.p1.W = make([]kyber.Point, k)
+	ps.p1.Lambda1 = make([]kyber.Point, width)
+	ps.p1.Lambda2 = make([]kyber.Point, width)
+	ps.v2.Zrho = make([]kyber.Scalar, k)
+	ps.p3.D = make([]kyber.Point, k)
+	ps.p5.Zsigma = make([]kyber.Scalar, k)
+	ps.p5.Ztau = make([]kyber.Scalar, width)
 	ps.pv6.Init(grp, k)
 
+	return ps, nil
+}
+
+// Whether all wide pair vectors have the given width and length.
+func fits(k, width int, V ...[][]kyber.Point) bool {
+	for _, columns := range V {
+		if len(columns) != width {
+			return false
+		}
+		for _, column := range columns {
+			if len(column) != k {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Split the per-pair computations of Prove and Verify, including those of the
+// embedded simple k-shuffle, across the given number of goroutines. Proofs
+// are identical for any number of workers, at most one runs serially. All
+// randomness is drawn before the work is split, workers never read a stream.
+func (ps *PairShuffle) SetWorkers(workers int) *PairShuffle {
+	ps.workers = workers
+	ps.pv6.workers = workers
 	return ps
 }
 
+// Re-encrypt with and compute the commitments to multiples of g from the
+// fixed-base tables of the election key. Tables of other points are ignored.
+func (ps *PairShuffle) SetBases(bases *elgamal.Bases) *PairShuffle {
+	ps.bases = bases
+	ps.pv6.bases = bases
+	return ps
+}
+
+// Multiplication by g, from the tables if they belong to g.
+func mulBase(grp kyber.Group, bases *elgamal.Bases,
+	g kyber.Point) func(kyber.Scalar) kyber.Point {
+
+	if bases != nil && bases.G.Equal(g) {
+		return bases.MulG
+	}
+	return func(s kyber.Scalar) kyber.Point {
+		return grp.Point().Mul(s, g)
+	}
+}
+
 func (ps *PairShuffle) Prove(
-	pi []int, g, h abstract.Point, beta []abstract.Scalar,
-	X, Y []abstract.Point, rand cipher.Stream,
+	pi []int, g, h kyber.Point, beta []kyber.Scalar,
+	X, Y []kyber.Point, rand cipher.Stream,
 	ctx proof.ProverContext) error {
 
+	return ps.ProveWide(pi, g, h, [][]kyber.Scalar{beta}, [][]kyber.Point{X},
+		[][]kyber.Point{Y}, rand, ctx)
+}
+
+// Prove a shuffle of wide ciphertexts given column by column, with the
+// blinding factors beta[j] of column j.
+func (ps *PairShuffle) ProveWide(
+	pi []int, g, h kyber.Point, beta [][]kyber.Scalar,
+	X, Y [][]kyber.Point, rand cipher.Stream,
+	ctx proof.ProverContext) error {
+
+	k := ps.k
+	if k != len(pi) || len(beta) != ps.width || !fits(k, ps.width, X, Y) {
+		return elgamal.ErrMismatchedLength
+	}
+	for _, b := range beta {
+		if len(b) != k {
+			return elgamal.ErrMismatchedLength
+		}
+	}
+
+	// pick random secrets
+	u := make([]kyber.Scalar, k)
+	w := make([]kyber.Scalar, k)
+	a := make([]kyber.Scalar, k)
+	tau0 := make([]kyber.Scalar, ps.width)
+	var nu, gamma kyber.Scalar
+	if err := ctx.PriRand(u, w, a, tau0, &nu, &gamma); err != nil {
+		return err
+	}
+
+	cm := ps.commit(pi, g, h, beta, u, w, a, tau0, gamma)
+	return ps.prove(cm, pi, g, beta, X, Y, rand, ctx)
+}
+
+// Secrets of the prover's first step and the commitments to them, neither
+// depends on the ciphertexts.
+type commitment struct {
+	u, w, a, tau0 []kyber.Scalar
+	gamma         kyber.Scalar
+	Gamma         kyber.Point
+	A, C, U, W    []kyber.Point
+
+	// The summands tau0*g + sum w_i beta_pi(i) g of Lambda1 and likewise
+	// with h of Lambda2, per column.
+	lambda1, lambda2 []kyber.Point
+}
+
+// P step 1 without the ciphertexts: compute the public commits.
+func (ps *PairShuffle) commit(pi []int, g, h kyber.Point,
+	beta [][]kyber.Scalar, u, w, a, tau0 []kyber.Scalar,
+	gamma kyber.Scalar) *commitment {
+
 	grp := ps.grp
 	k := ps.k
-	if k != len(pi) || k != len(beta) {
-		panic("mismatched vector lengths")
+	mulg := mulBase(grp, ps.bases, g)
+	mulh := func(s kyber.Scalar) kyber.Point {
+		return grp.Point().Mul(s, h)
+	}
+	if ps.bases != nil && ps.bases.Match(g, h) {
+		mulh = ps.bases.MulH
 	}
 
-	// Compute pi^-1 inverse permutation
-	piinv := make([]int, k)
-	for i := 0; i < k; i++ {
-		piinv[pi[i]] = i
+	cm := &commitment{
+		u: u, w: w, a: a, tau0: tau0, gamma: gamma,
+		Gamma:   mulg(gamma),
+		A:       make([]kyber.Point, k),
+		C:       make([]kyber.Point, k),
+		U:       make([]kyber.Point, k),
+		W:       make([]kyber.Point, k),
+		lambda1: make([]kyber.Point, ps.width),
+		lambda2: make([]kyber.Point, ps.width),
+	}
+
+	// the sums are accumulated per chunk and column
+	wbetasums := make([][]kyber.Scalar, chunks(k, ps.workers))
+	each(k, ps.workers, func(c, lo, hi int) {
+		z := grp.Scalar()
+		for i := lo; i < hi; i++ {
+			cm.A[i] = mulg(a[i])
+			cm.C[i] = mulg(z.Mul(gamma, a[pi[i]]))
+			cm.U[i] = mulg(u[i])
+			cm.W[i] = mulg(z.Mul(gamma, w[i]))
+		}
+		wbetasums[c] = make([]kyber.Scalar, ps.width)
+		for j := range beta {
+			wbetasums[c][j] = grp.Scalar().Zero()
+			for i := lo; i < hi; i++ {
+				wbetasums[c][j].Add(wbetasums[c][j], z.Mul(w[i], beta[j][pi[i]]))
+			}
+		}
+	})
+	for j := range beta {
+		wbetasum := grp.Scalar().Set(tau0[j])
+		for _, sums := range wbetasums {
+			wbetasum.Add(wbetasum, sums[j])
+		}
+		cm.lambda1[j] = mulg(wbetasum)
+		cm.lambda2[j] = mulh(wbetasum)
 	}
 
+	return cm
+}
+
+// Remaining prover steps once the ciphertexts are known.
+func (ps *PairShuffle) prove(cm *commitment, pi []int, g kyber.Point,
+	beta [][]kyber.Scalar, X, Y [][]kyber.Point, rand cipher.Stream,
+	ctx proof.ProverContext) error {
+
+	grp := ps.grp
+	k := ps.k
+	u, w, a, tau0, gamma := cm.u, cm.w, cm.a, cm.tau0, cm.gamma
+	mulg := mulBase(grp, ps.bases, g)
+
+	piinv := permutation.Inverse(pi)
+
 	// P step 1
 	p1 := &ps.p1
 	z := grp.Scalar()
-
-	// pick random secrets
-	u := make([]abstract.Scalar, k)
-	w := make([]abstract.Scalar, k)
-	a := make([]abstract.Scalar, k)
-	var tau0, nu, gamma abstract.Scalar
-	ctx.PriRand(u, w, a, &tau0, &nu, &gamma)
-
-	// compute public commits
-	p1.Gamma = grp.Point().Mul(g, gamma)
-	wbeta := grp.Scalar()
-	wbetasum := grp.Scalar().Set(tau0)
-	p1.Lambda1 = grp.Point().Null()
-	p1.Lambda2 = grp.Point().Null()
-	XY := grp.Point()
-	wu := grp.Scalar()
-	for i := 0; i < k; i++ {
-		p1.A[i] = grp.Point().Mul(g, a[i])
-		p1.C[i] = grp.Point().Mul(g, z.Mul(gamma, a[pi[i]]))
-		p1.U[i] = grp.Point().Mul(g, u[i])
-		p1.W[i] = grp.Point().Mul(g, z.Mul(gamma, w[i]))
-		wbetasum.Add(wbetasum, wbeta.Mul(w[i], beta[pi[i]]))
-		p1.Lambda1.Add(p1.Lambda1, XY.Mul(X[i],
-			wu.Sub(w[piinv[i]], u[i])))
-		p1.Lambda2.Add(p1.Lambda2, XY.Mul(Y[i],
-			wu.Sub(w[piinv[i]], u[i])))
-	}
-	p1.Lambda1.Add(p1.Lambda1, XY.Mul(g, wbetasum))
-	p1.Lambda2.Add(p1.Lambda2, XY.Mul(h, wbetasum))
+	p1.Gamma = cm.Gamma
+	copy(p1.A, cm.A)
+	copy(p1.C, cm.C)
+	copy(p1.U, cm.U)
+	copy(p1.W, cm.W)
+
+	n := chunks(k, ps.workers)
+	lambda1 := make([][]kyber.Point, n)
+	lambda2 := make([][]kyber.Point, n)
+	each(k, ps.workers, func(c, lo, hi int) {
+		wu := make([]kyber.Scalar, hi-lo)
+		for i := lo; i < hi; i++ {
+			wu[i-lo] = grp.Scalar().Sub(w[piinv[i]], u[i])
+		}
+		lambda1[c] = make([]kyber.Point, ps.width)
+		lambda2[c] = make([]kyber.Point, ps.width)
+		// wu depends on the permutation, see multiexp.MulSecret.
+		for j := range X {
+			lambda1[c][j] = multiexp.MulSecret(grp, wu, X[j][lo:hi])
+			lambda2[c][j] = multiexp.MulSecret(grp, wu, Y[j][lo:hi])
+		}
+	})
+	for j := range X {
+		p1.Lambda1[j] = grp.Point().Set(cm.lambda1[j])
+		p1.Lambda2[j] = grp.Point().Set(cm.lambda2[j])
+		for c := 0; c < n; c++ {
+			p1.Lambda1[j].Add(p1.Lambda1[j], lambda1[c][j])
+			p1.Lambda2[j].Add(p1.Lambda2[j], lambda2[c][j])
+		}
+	}
 	if err := ctx.Put(p1); err != nil {
 		return err
 	}
@@ -130,23 +317,27 @@ func (ps *PairShuffle) Prove(
 	if err := ctx.PubRand(v2); err != nil {
 		return err
 	}
-	B := make([]abstract.Point, k)
-	for i := 0; i < k; i++ {
-		P := grp.Point().Mul(g, v2.Zrho[i])
-		B[i] = P.Sub(P, p1.U[i])
-	}
+	B := make([]kyber.Point, k)
+	each(k, ps.workers, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			P := mulg(v2.Zrho[i])
+			B[i] = P.Sub(P, p1.U[i])
+		}
+	})
 
 	// P step 3
 	p3 := &ps.p3
-	b := make([]abstract.Scalar, k)
+	b := make([]kyber.Scalar, k)
 	for i := 0; i < k; i++ {
 		b[i] = grp.Scalar().Sub(v2.Zrho[i], u[i])
 	}
-	d := make([]abstract.Scalar, k)
-	for i := 0; i < k; i++ {
-		d[i] = grp.Scalar().Mul(gamma, b[pi[i]])
-		p3.D[i] = grp.Point().Mul(g, d[i])
-	}
+	d := make([]kyber.Scalar, k)
+	each(k, ps.workers, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			d[i] = grp.Scalar().Mul(gamma, b[pi[i]])
+			p3.D[i] = mulg(d[i])
+		}
+	})
 	if err := ctx.Put(p3); err != nil {
 		return err
 	}
@@ -159,35 +350,57 @@ func (ps *PairShuffle) Prove(
 
 	// P step 5
 	p5 := &ps.p5
-	r := make([]abstract.Scalar, k)
+	r := make([]kyber.Scalar, k)
 	for i := 0; i < k; i++ {
 		r[i] = grp.Scalar().Add(a[i], z.Mul(v4.Zlambda, b[i]))
 	}
-	s := make([]abstract.Scalar, k)
+	s := make([]kyber.Scalar, k)
 	for i := 0; i < k; i++ {
 		s[i] = grp.Scalar().Mul(gamma, r[pi[i]])
 	}
-	p5.Ztau = grp.Scalar().Neg(tau0)
 	for i := 0; i < k; i++ {
 		p5.Zsigma[i] = grp.Scalar().Add(w[i], b[pi[i]])
-		p5.Ztau.Add(p5.Ztau, z.Mul(b[i], beta[i]))
+	}
+	for j := range beta {
+		p5.Ztau[j] = grp.Scalar().Neg(tau0[j])
+		for i := 0; i < k; i++ {
+			p5.Ztau[j].Add(p5.Ztau[j], z.Mul(b[i], beta[j][i]))
+		}
 	}
 	if err := ctx.Put(p5); err != nil {
 		return err
 	}
 
 	// P,V step 6: embedded simple k-shuffle proof
-	return ps.pv6.Prove(g, gamma, r, s, rand, ctx)
+	start := time.Now()
+	err := ps.pv6.Prove(g, gamma, r, s, rand, ctx)
+	ps.simple = time.Since(start)
+
+	return err
+}
+
+// Time spent in the embedded simple k-shuffle proof during the last Prove.
+func (ps *PairShuffle) SimpleTime() time.Duration {
+	return ps.simple
 }
 
 func (ps *PairShuffle) Verify(
-	g, h abstract.Point, X, Y, Xbar, Ybar []abstract.Point,
+	g, h kyber.Point, X, Y, Xbar, Ybar []kyber.Point,
+	ctx proof.VerifierContext) error {
+
+	return ps.VerifyWide(g, h, [][]kyber.Point{X}, [][]kyber.Point{Y},
+		[][]kyber.Point{Xbar}, [][]kyber.Point{Ybar}, ctx)
+}
+
+// Verify a shuffle of wide ciphertexts given column by column.
+func (ps *PairShuffle) VerifyWide(
+	g, h kyber.Point, X, Y, Xbar, Ybar [][]kyber.Point,
 	ctx proof.VerifierContext) error {
 
 	grp := ps.grp
 	k := ps.k
-	if len(X) != k || len(Y) != k || len(Xbar) != k || len(Ybar) != k {
-		panic("mismatched vector lengths")
+	if !fits(ps.k, ps.width, X, Y, Xbar, Ybar) {
+		return elgamal.ErrMismatchedLength
 	}
 
 	// P step 1
@@ -201,11 +414,13 @@ func (ps *PairShuffle) Verify(
 	if err := ctx.PubRand(v2); err != nil {
 		return err
 	}
-	B := make([]abstract.Point, k)
-	for i := 0; i < k; i++ {
-		P := grp.Point().Mul(g, v2.Zrho[i])
-		B[i] = P.Sub(P, p1.U[i])
-	}
+	B := make([]kyber.Point, k)
+	each(k, ps.workers, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			P := grp.Point().Mul(v2.Zrho[i], g)
+			B[i] = P.Sub(P, p1.U[i])
+		}
+	})
 
 	// P step 3
 	p3 := &ps.p3
@@ -230,57 +445,202 @@ func (ps *PairShuffle) Verify(
 		return err
 	}
 
-	// V step 7
-	Phi1 := grp.Point().Null()
-	Phi2 := grp.Point().Null()
-	P := grp.Point()
-	Q := grp.Point()
-	for i := 0; i < k; i++ {
-		Phi1 = Phi1.Add(Phi1, P.Mul(Xbar[i], p5.Zsigma[i]))
-		Phi1 = Phi1.Sub(Phi1, P.Mul(X[i], v2.Zrho[i]))
-		Phi2 = Phi2.Add(Phi2, P.Mul(Ybar[i], p5.Zsigma[i]))
-		Phi2 = Phi2.Sub(Phi2, P.Mul(Y[i], v2.Zrho[i]))
-		if !P.Mul(p1.Gamma, p5.Zsigma[i]).Equal(
-			Q.Add(p1.W[i], p3.D[i])) {
+	if ps.batch != nil {
+		ps.defer7(g, h, X, Y, Xbar, Ybar)
+		return nil
+	}
+
+	// V step 7, the sums are accumulated per chunk and column
+	n := chunks(k, ps.workers)
+	phi1 := make([][]kyber.Point, n)
+	phi2 := make([][]kyber.Point, n)
+	good := make([]bool, n)
+	each(k, ps.workers, func(c, lo, hi int) {
+		// Phi1 = sum sigma_i Xbar_i - rho_i X_i and Phi2 likewise over Y.
+		m := hi - lo
+		s := make([]kyber.Scalar, 2*m)
+		for i := lo; i < hi; i++ {
+			s[i-lo], s[m+i-lo] = p5.Zsigma[i], grp.Scalar().Neg(v2.Zrho[i])
+		}
+		phi1[c] = make([]kyber.Point, ps.width)
+		phi2[c] = make([]kyber.Point, ps.width)
+		XX := make([]kyber.Point, 2*m)
+		YY := make([]kyber.Point, 2*m)
+		for j := range X {
+			copy(XX, Xbar[j][lo:hi])
+			copy(XX[m:], X[j][lo:hi])
+			copy(YY, Ybar[j][lo:hi])
+			copy(YY[m:], Y[j][lo:hi])
+			phi1[c][j] = multiexp.Mul(grp, s, XX)
+			phi2[c][j] = multiexp.Mul(grp, s, YY)
+		}
+
+		P := grp.Point()
+		Q := grp.Point()
+		good[c] = true
+		for i := lo; i < hi; i++ {
+			good[c] = good[c] && P.Mul(p5.Zsigma[i], p1.Gamma).Equal(
+				Q.Add(p1.W[i], p3.D[i]))
+		}
+	})
+	for c := 0; c < n; c++ {
+		if !good[c] {
 			return errors.New("invalid PairShuffleProof")
 		}
 	}
 
-	if !P.Add(p1.Lambda1, Q.Mul(g, p5.Ztau)).Equal(Phi1) ||
-		!P.Add(p1.Lambda2, Q.Mul(h, p5.Ztau)).Equal(Phi2) {
-		return errors.New("invalid PairShuffleProof")
+	P := grp.Point()
+	Q := grp.Point()
+	for j := range X {
+		Phi1 := grp.Point().Null()
+		Phi2 := grp.Point().Null()
+		for c := 0; c < n; c++ {
+			Phi1.Add(Phi1, phi1[c][j])
+			Phi2.Add(Phi2, phi2[c][j])
+		}
+
+		if !P.Add(p1.Lambda1[j], Q.Mul(p5.Ztau[j], g)).Equal(Phi1) ||
+			!P.Add(p1.Lambda2[j], Q.Mul(p5.Ztau[j], h)).Equal(Phi2) {
+			return errors.New("invalid PairShuffleProof")
+		}
 	}
 
 	return nil
 }
 
-func Shuffle(group abstract.Group, g, h abstract.Point, X, Y []abstract.Point,
-	rand cipher.Stream) (XX, YY []abstract.Point, P proof.Prover) {
+// Add the equations of verifier step 7 to the batch: sigma_i Gamma = W_i + D_i
+// for every i, Lambda1 + tau g = Phi1 and Lambda2 + tau h = Phi2 for every
+// column.
+func (ps *PairShuffle) defer7(g, h kyber.Point, X, Y, Xbar, Ybar [][]kyber.Point) {
+	grp := ps.grp
+	b := ps.batch
+	p1, v2, p3, p5 := &ps.p1, &ps.v2, &ps.p3, &ps.p5
+
+	gamma := grp.Scalar().Zero()
+	for i := 0; i < ps.k; i++ {
+		r := b.weight()
+		gamma.Add(gamma, grp.Scalar().Mul(r, p5.Zsigma[i]))
+		b.term(grp.Scalar().Neg(r), p1.W[i])
+		b.term(grp.Scalar().Neg(r), p3.D[i])
+	}
+	b.term(gamma, p1.Gamma)
+
+	for j := range X {
+		for _, e := range []struct {
+			Lambda, base kyber.Point
+			V, Vbar      []kyber.Point
+		}{{p1.Lambda1[j], g, X[j], Xbar[j]}, {p1.Lambda2[j], h, Y[j], Ybar[j]}} {
+			r := b.weight()
+			b.term(r, e.Lambda)
+			b.term(grp.Scalar().Mul(r, p5.Ztau[j]), e.base)
+			for i := 0; i < ps.k; i++ {
+				rs := grp.Scalar().Mul(r, p5.Zsigma[i])
+				b.term(rs.Neg(rs), e.Vbar[i])
+				b.term(grp.Scalar().Mul(r, v2.Zrho[i]), e.V[i])
+			}
+		}
+	}
+}
+
+func Shuffle(group kyber.Group, g, h kyber.Point, X, Y []kyber.Point,
+	rand cipher.Stream) (XX, YY []kyber.Point, P proof.Prover, err error) {
 
 	k := len(X)
 	if k != len(Y) {
-		panic("X,Y vectors have inconsistent length")
+		return nil, nil, nil, elgamal.ErrMismatchedLength
+	}
+
+	ps := PairShuffle{}
+	if _, err := ps.Init(group, k); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ps.Shuffle(g, h, X, Y, rand)
+}
+
+// Shuffle wide ciphertexts given column by column, see
+// elgamal.PermuteWide.
+func ShuffleWide(group kyber.Group, g, h kyber.Point, X, Y [][]kyber.Point,
+	rand cipher.Stream) (XX, YY [][]kyber.Point, P proof.Prover, err error) {
+
+	if len(X) == 0 || !fits(len(X[0]), len(X), X, Y) {
+		return nil, nil, nil, elgamal.ErrMismatchedLength
 	}
 
 	ps := PairShuffle{}
-	ps.Init(group, k)
+	if _, err := ps.InitWide(group, len(X[0]), len(X)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ps.ShuffleWide(g, h, X, Y, rand)
+}
 
-	Xbar, Ybar, pi, beta := elgamal.Permute(group, g, h, X, Y, rand)
+// Shuffle the pairs with a fresh permutation and return the prover of this
+// shuffle bound to ps.
+func (ps *PairShuffle) Shuffle(g, h kyber.Point, X, Y []kyber.Point,
+	rand cipher.Stream) (XX, YY []kyber.Point, P proof.Prover, err error) {
+
+	Xbar, Ybar, prover, err := ps.ShuffleWide(g, h, [][]kyber.Point{X},
+		[][]kyber.Point{Y}, rand)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return Xbar[0], Ybar[0], prover, nil
+}
+
+// Shuffle the rows of wide ciphertexts given column by column with a fresh
+// permutation and return the prover of this shuffle bound to ps.
+func (ps *PairShuffle) ShuffleWide(g, h kyber.Point, X, Y [][]kyber.Point,
+	rand cipher.Stream) (XX, YY [][]kyber.Point, P proof.Prover, err error) {
+
+	if !fits(ps.k, ps.width, X, Y) {
+		return nil, nil, nil, elgamal.ErrMismatchedLength
+	}
+
+	var Xbar, Ybar [][]kyber.Point
+	var pi []int
+	var beta [][]kyber.Scalar
+	if ps.bases != nil && ps.bases.Match(g, h) {
+		Xbar, Ybar, pi, beta, err = ps.bases.PermuteWide(X, Y, rand)
+	} else {
+		Xbar, Ybar, pi, beta, err = elgamal.PermuteWide(ps.grp, g, h, X, Y, rand)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	prover := func(ctx proof.ProverContext) error {
-		return ps.Prove(pi, g, h, beta, X, Y, rand, ctx)
+		return ps.ProveWide(pi, g, h, beta, X, Y, rand, ctx)
 	}
 
-	return Xbar, Ybar, prover
+	return Xbar, Ybar, prover, nil
+}
+
+// Verifier for Neff shuffle proofs splitting the verification across the
+// given number of goroutines, at most one verifies serially.
+func Verifier(group kyber.Group, g, h kyber.Point,
+	X, Y, Xbar, Ybar []kyber.Point, workers int) (proof.Verifier, error) {
+
+	return VerifierWide(group, g, h, [][]kyber.Point{X}, [][]kyber.Point{Y},
+		[][]kyber.Point{Xbar}, [][]kyber.Point{Ybar}, workers)
 }
 
-func Verifier(group abstract.Group, g, h abstract.Point,
-	X, Y, Xbar, Ybar []abstract.Point) proof.Verifier {
+// Verifier for Neff proofs of shuffles of wide ciphertexts given column by
+// column.
+func VerifierWide(group kyber.Group, g, h kyber.Point,
+	X, Y, Xbar, Ybar [][]kyber.Point, workers int) (proof.Verifier, error) {
+
+	if len(X) == 0 || !fits(len(X[0]), len(X), X, Y, Xbar, Ybar) {
+		return nil, elgamal.ErrMismatchedLength
+	}
 
 	ps := PairShuffle{}
-	ps.Init(group, len(X))
+	if _, err := ps.InitWide(group, len(X[0]), len(X)); err != nil {
+		return nil, err
+	}
+	ps.SetWorkers(workers)
 
 	return func(ctx proof.VerifierContext) error {
-		return ps.Verify(g, h, X, Y, Xbar, Ybar, ctx)
-	}
+		return ps.VerifyWide(g, h, X, Y, Xbar, Ybar, ctx)
+	}, nil
 }
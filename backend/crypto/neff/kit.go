@@ -0,0 +1,160 @@
+package neff
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/permutation"
+)
+
+// The kit was already used for a shuffle.
+var ErrKitUsed = errors.New("shuffle kit already used")
+
+// Everything of a Neff shuffle that does not depend on the ciphertexts,
+// prepared before the ballots arrive: the permutation, the re-encryption
+// factors with their multiples of g and h, the prover's secrets and the
+// commitments of its first step. Shuffling two vectors with the same kit
+// would link them and leak the secrets, a kit therefore serves one shuffle
+// and one proof, after which its secrets are wiped.
+type Kit struct {
+	g, h         kyber.Point
+	pi           []int
+	beta         [][]kyber.Scalar
+	gbeta, hbeta [][]kyber.Point
+	cm           *commitment
+	theta        []kyber.Scalar
+	used         bool
+}
+
+// Prepare a kit for a shuffle of k rows of pairs under g and h, as wide as
+// the shuffle. The randomness is drawn in the order Shuffle and HashProve
+// draw it, so that a seeded kit gives the same shuffle and proof.
+func (ps *PairShuffle) Precompute(g, h kyber.Point, rand cipher.Stream) *Kit {
+	grp := ps.grp
+	k := ps.k
+
+	kit := &Kit{g: g, h: h, pi: permutation.Random(k, rand)}
+	kit.beta = make([][]kyber.Scalar, ps.width)
+	for j := range kit.beta {
+		kit.beta[j] = pick(grp, k, rand)
+	}
+
+	mulg := mulBase(grp, ps.bases, g)
+	mulh := func(s kyber.Scalar) kyber.Point {
+		return grp.Point().Mul(s, h)
+	}
+	if ps.bases != nil && ps.bases.Match(g, h) {
+		mulh = ps.bases.MulH
+	}
+	kit.gbeta = make([][]kyber.Point, ps.width)
+	kit.hbeta = make([][]kyber.Point, ps.width)
+	for j, beta := range kit.beta {
+		gbeta := make([]kyber.Point, k)
+		hbeta := make([]kyber.Point, k)
+		each(k, ps.workers, func(_, lo, hi int) {
+			for i := lo; i < hi; i++ {
+				gbeta[i] = mulg(beta[i])
+				hbeta[i] = mulh(beta[i])
+			}
+		})
+		kit.gbeta[j], kit.hbeta[j] = gbeta, hbeta
+	}
+
+	u := pick(grp, k, rand)
+	w := pick(grp, k, rand)
+	a := pick(grp, k, rand)
+	tau0 := pick(grp, ps.width, rand)
+	grp.Scalar().Pick(rand) // nu
+	gamma := grp.Scalar().Pick(rand)
+	kit.cm = ps.commit(kit.pi, g, h, kit.beta, u, w, a, tau0, gamma)
+	kit.theta = pick(grp, 2*k-1, rand)
+
+	return kit
+}
+
+func pick(grp kyber.Group, n int, rand cipher.Stream) []kyber.Scalar {
+	s := make([]kyber.Scalar, n)
+	for i := range s {
+		s[i] = grp.Scalar().Pick(rand)
+	}
+	return s
+}
+
+// Shuffle the pairs with a kit made by Precompute, which leaves additions
+// for the re-encryption and mostly multiplications by g for the proof.
+func (ps *PairShuffle) ShuffleKit(kit *Kit, X, Y []kyber.Point) (XX,
+	YY []kyber.Point, P proof.Prover, err error) {
+
+	Xbar, Ybar, prover, err := ps.ShuffleKitWide(kit, [][]kyber.Point{X},
+		[][]kyber.Point{Y})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return Xbar[0], Ybar[0], prover, nil
+}
+
+// Shuffle wide ciphertexts given column by column with a kit made by
+// Precompute.
+func (ps *PairShuffle) ShuffleKitWide(kit *Kit, X, Y [][]kyber.Point) (XX,
+	YY [][]kyber.Point, P proof.Prover, err error) {
+
+	k := ps.k
+	if kit.used {
+		return nil, nil, nil, ErrKitUsed
+	}
+	if !fits(k, ps.width, X, Y) || len(kit.pi) != k || len(kit.beta) != ps.width {
+		return nil, nil, nil, elgamal.ErrMismatchedLength
+	}
+	kit.used = true
+
+	Xbar := make([][]kyber.Point, ps.width)
+	Ybar := make([][]kyber.Point, ps.width)
+	for c := range X {
+		Xbar[c] = make([]kyber.Point, k)
+		Ybar[c] = make([]kyber.Point, k)
+		for i, j := range kit.pi {
+			Xbar[c][i] = ps.grp.Point().Add(kit.gbeta[c][j], X[c][j])
+			Ybar[c][i] = ps.grp.Point().Add(kit.hbeta[c][j], Y[c][j])
+		}
+	}
+
+	// The prover runs once, a second proof would reveal the secrets.
+	proved := false
+	prover := func(ctx proof.ProverContext) error {
+		if proved {
+			return ErrKitUsed
+		}
+		proved = true
+		defer kit.clear()
+		ps.pv6.theta = kit.theta
+		return ps.prove(kit.cm, kit.pi, kit.g, kit.beta, X, Y, nil, ctx)
+	}
+
+	return Xbar, Ybar, prover, nil
+}
+
+// Zero the secrets of a used kit and drop them.
+func (kit *Kit) clear() {
+	zero := func(s []kyber.Scalar) {
+		for _, s := range s {
+			s.Zero()
+		}
+	}
+	for _, beta := range kit.beta {
+		zero(beta)
+	}
+	cm := kit.cm
+	for _, s := range [][]kyber.Scalar{cm.u, cm.w, cm.a, cm.tau0, kit.theta,
+		{cm.gamma}} {
+		zero(s)
+	}
+	for i := range kit.pi {
+		kit.pi[i] = 0
+	}
+	kit.pi, kit.beta, kit.gbeta, kit.hbeta = nil, nil, nil, nil
+	kit.cm, kit.theta = nil, nil
+}
@@ -0,0 +1,810 @@
+package neff
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+// Public statement of a shuffle proof.
+type statement struct {
+	g, h       kyber.Point
+	X, Y       []kyber.Point
+	Xbar, Ybar []kyber.Point
+}
+
+// Encrypt k ballots in the named suite and shuffle them with randomness
+// derived from the test name. Returns the statement, the proof and the
+// prover, which may be run again.
+func shuffle(t testing.TB, name string, k int) (suites.Suite, *statement, []byte,
+	proof.Prover) {
+
+	return seededShuffle(t, name, t.Name(), k)
+}
+
+func seededShuffle(t testing.TB, name, seed string, k int) (suites.Suite, *statement,
+	[]byte, proof.Prover) {
+
+	suite, err := suites.Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite = suites.Seeded(suite, []byte(seed))
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+
+	st := &statement{g: suite.Point().Base(), h: key.Public}
+	st.X, st.Y = encrypt(suite, key.Public, k)
+
+	var prover proof.Prover
+	st.Xbar, st.Ybar, prover, err = Shuffle(suite, st.g, st.h, st.X, st.Y, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := proof.HashProve(suite, "PS", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return suite, st, stamp, prover
+}
+
+func encrypt(suite suites.Suite, h kyber.Point, k int) (X, Y []kyber.Point) {
+	stream := suite.RandomStream()
+	X = make([]kyber.Point, k)
+	Y = make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		X[i], Y[i], _ = elgamal.Encrypt(suite, h, []byte(fmt.Sprintf("vote#%d", i)), stream)
+	}
+	return
+}
+
+func verify(suite suites.Suite, st *statement, stamp []byte) error {
+	verifier, err := Verifier(suite, st.g, st.h, st.X, st.Y, st.Xbar, st.Ybar, 1)
+	if err != nil {
+		return err
+	}
+	return proof.HashVerify(suite, "PS", verifier, stamp)
+}
+
+// Copy of the statement whose vectors may be modified.
+func (st *statement) clone() *statement {
+	c := *st
+	for _, v := range []*[]kyber.Point{&c.X, &c.Y, &c.Xbar, &c.Ybar} {
+		*v = append([]kyber.Point(nil), *v...)
+	}
+	return &c
+}
+
+func TestCompleteness(t *testing.T) {
+	for _, name := range []string{"P256", "Ed25519", "Residue512"} {
+		for _, k := range []int{2, 3, 10} {
+			t.Run(fmt.Sprintf("%s/k=%d", name, k), func(t *testing.T) {
+				suite, st, stamp, _ := shuffle(t, name, k)
+				if err := verify(suite, st, stamp); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+// Proofs do not depend on the number of workers and verify with any of them.
+func TestWorkers(t *testing.T) {
+	suite, st, stamp, _ := shuffle(t, "P256", 10)
+	for _, workers := range []int{2, 3, 16} {
+		verifier, err := Verifier(suite, st.g, st.h, st.X, st.Y, st.Xbar, st.Ybar, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := proof.HashVerify(suite, "PS", verifier, stamp); err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+
+		forged := st.clone()
+		forged.Ybar[9] = suite.Point().Add(st.Ybar[9], st.g)
+		verifier, _ = Verifier(suite, forged.g, forged.h, forged.X, forged.Y,
+			forged.Xbar, forged.Ybar, workers)
+		if proof.HashVerify(suite, "PS", verifier, stamp) == nil {
+			t.Fatalf("%d workers accepted a modified output ciphertext", workers)
+		}
+	}
+
+	// Rerun the seeded shuffle with a parallel prover.
+	seeded, _ := suites.Lookup("P256")
+	seeded = suites.Seeded(seeded, []byte(t.Name()))
+	stream := seeded.RandomStream()
+	key := elgamal.GenerateKey(seeded, stream)
+	X, Y := encrypt(seeded, key.Public, 10)
+	ps, err := new(PairShuffle).Init(seeded, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, prover, err := ps.SetWorkers(3).Shuffle(st.g, key.Public, X, Y, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := proof.HashProve(seeded, "PS", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parallel, stamp) {
+		t.Fatal("parallel prover produced a different proof")
+	}
+}
+
+// Shuffles with fixed-base tables are identical to those without and tables
+// of other points are ignored.
+func TestBases(t *testing.T) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		t.Run(name, func(t *testing.T) {
+			suite, st, stamp, _ := shuffle(t, name, 10)
+
+			for _, bases := range []*elgamal.Bases{
+				elgamal.NewBases(suite, st.g, st.h),
+				elgamal.NewBases(suite, st.h, st.g),
+			} {
+				// Replay the seeded shuffle up to the encryption.
+				seeded, _ := suites.Lookup(name)
+				seeded = suites.Seeded(seeded, []byte(t.Name()))
+				stream := seeded.RandomStream()
+				elgamal.GenerateKey(seeded, stream)
+				X, Y := encrypt(seeded, st.h, 10)
+
+				ps, err := new(PairShuffle).Init(seeded, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				Xbar, _, prover, err := ps.SetBases(bases).Shuffle(st.g, st.h, X, Y, stream)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tabled, err := proof.HashProve(seeded, "PS", prover)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(tabled, stamp) || !Xbar[0].Equal(st.Xbar[0]) {
+					t.Fatal("tables changed the shuffle")
+				}
+			}
+		})
+	}
+}
+
+// A seeded kit gives the same shuffle and proof as Shuffle and serves a single
+// shuffle.
+func TestKit(t *testing.T) {
+	for _, name := range []string{"Ed25519", "P256"} {
+		t.Run(name, func(t *testing.T) {
+			suite, st, stamp, _ := shuffle(t, name, 10)
+
+			for _, bases := range []*elgamal.Bases{nil, elgamal.NewBases(suite, st.g, st.h)} {
+				seeded, _ := suites.Lookup(name)
+				seeded = suites.Seeded(seeded, []byte(t.Name()))
+				stream := seeded.RandomStream()
+				elgamal.GenerateKey(seeded, stream)
+				X, Y := encrypt(seeded, st.h, 10)
+
+				ps, err := new(PairShuffle).Init(seeded, 10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				kit := ps.SetBases(bases).SetWorkers(3).Precompute(st.g, st.h, stream)
+				Xbar, Ybar, prover, err := ps.ShuffleKit(kit, X, Y)
+				if err != nil {
+					t.Fatal(err)
+				}
+				online, err := proof.HashProve(seeded, "PS", prover)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(online, stamp) {
+					t.Fatal("kit produced a different proof")
+				}
+				if _, err := proof.HashProve(seeded, "PS", prover); err != ErrKitUsed {
+					t.Fatalf("second proof with the kit: %v", err)
+				}
+				if kit.theta != nil || kit.beta != nil || kit.cm != nil {
+					t.Fatal("kit secrets kept after the proof")
+				}
+				for i := range Xbar {
+					if !Xbar[i].Equal(st.Xbar[i]) || !Ybar[i].Equal(st.Ybar[i]) {
+						t.Fatalf("kit produced a different pair %d", i)
+					}
+				}
+
+				if _, _, _, err := ps.ShuffleKit(kit, X, Y); err != ErrKitUsed {
+					t.Fatalf("reused kit: %v", err)
+				}
+			}
+
+			ps, _ := new(PairShuffle).Init(suite, 10)
+			kit := ps.Precompute(st.g, st.h, suite.RandomStream())
+			if _, _, _, err := ps.ShuffleKit(kit, st.X[:9], st.Y[:9]); err != elgamal.ErrMismatchedLength {
+				t.Fatalf("mismatched vectors: %v", err)
+			}
+		})
+	}
+}
+
+// Encrypt k ballots of the given number of contests, column by column.
+func encryptWide(suite suites.Suite, h kyber.Point, k, width int) (X,
+	Y [][]kyber.Point) {
+
+	stream := suite.RandomStream()
+	X = make([][]kyber.Point, width)
+	Y = make([][]kyber.Point, width)
+	for j := range X {
+		X[j] = make([]kyber.Point, k)
+		Y[j] = make([]kyber.Point, k)
+		for i := 0; i < k; i++ {
+			X[j][i], Y[j][i], _ = elgamal.Encrypt(suite, h,
+				[]byte(fmt.Sprintf("vote#%d/%d", i, j)), stream)
+		}
+	}
+	return
+}
+
+func verifyWide(suite suites.Suite, g, h kyber.Point, X, Y, Xbar,
+	Ybar [][]kyber.Point, stamp []byte, workers int) error {
+
+	verifier, err := VerifierWide(suite, g, h, X, Y, Xbar, Ybar, workers)
+	if err != nil {
+		return err
+	}
+	return proof.HashVerify(suite, "PS", verifier, stamp)
+}
+
+// All columns of wide ciphertexts are shuffled with one permutation and a
+// kit gives the same wide shuffle.
+func TestWide(t *testing.T) {
+	for _, name := range []string{"P256", "Ed25519"} {
+		t.Run(name, func(t *testing.T) {
+			k, width := 5, 3
+			var suite suites.Suite
+			var g, h kyber.Point
+			run := func(kit bool) (X, Y, Xbar, Ybar [][]kyber.Point, stamp []byte) {
+				suite, _ = suites.Lookup(name)
+				suite = suites.Seeded(suite, []byte(t.Name()))
+				stream := suite.RandomStream()
+				g, h = suite.Point().Base(), elgamal.GenerateKey(suite, stream).Public
+				X, Y = encryptWide(suite, h, k, width)
+
+				ps, err := new(PairShuffle).InitWide(suite, k, width)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var prover proof.Prover
+				if kit {
+					Xbar, Ybar, prover, err = ps.ShuffleKitWide(ps.Precompute(g, h, stream), X, Y)
+				} else {
+					Xbar, Ybar, prover, err = ps.ShuffleWide(g, h, X, Y, stream)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stamp, err = proof.HashProve(suite, "PS", prover); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			X, Y, Xbar, Ybar, stamp := run(false)
+			for _, workers := range []int{1, 3} {
+				if err := verifyWide(suite, g, h, X, Y, Xbar, Ybar, stamp, workers); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, _, _, _, online := run(true)
+			if !bytes.Equal(online, stamp) {
+				t.Fatal("kit produced a different wide proof")
+			}
+
+			// The first components of two rows of a single column swapped.
+			forged := append([][]kyber.Point(nil), Xbar...)
+			forged[1] = append([]kyber.Point(nil), Xbar[1]...)
+			forged[1][0], forged[1][1] = Xbar[1][1], Xbar[1][0]
+			if verifyWide(suite, g, h, X, Y, forged, Ybar, stamp, 1) == nil {
+				t.Fatal("proof verified with a column partly permuted differently")
+			}
+
+			// A single column is a valid shuffle of its input, but under a
+			// permutation of its own: whole pairs of two rows are swapped, or
+			// the column is shuffled afresh.
+			swapX := append([][]kyber.Point(nil), Xbar...)
+			swapY := append([][]kyber.Point(nil), Ybar...)
+			swapX[2] = append([]kyber.Point(nil), Xbar[2]...)
+			swapY[2] = append([]kyber.Point(nil), Ybar[2]...)
+			swapX[2][0], swapX[2][3] = Xbar[2][3], Xbar[2][0]
+			swapY[2][0], swapY[2][3] = Ybar[2][3], Ybar[2][0]
+			if verifyWide(suite, g, h, X, Y, swapX, swapY, stamp, 1) == nil {
+				t.Fatal("proof verified with two rows of a column swapped")
+			}
+			freshX := append([][]kyber.Point(nil), Xbar...)
+			freshY := append([][]kyber.Point(nil), Ybar...)
+			var err error
+			freshX[0], freshY[0], _, _, err = elgamal.Permute(suite, g, h, X[0], Y[0],
+				suite.RandomStream())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verifyWide(suite, g, h, X, Y, freshX, freshY, stamp, 3) == nil {
+				t.Fatal("proof verified with a column shuffled independently")
+			}
+
+			// Wide instances are batch verified like pair shuffles.
+			in := Instance{Name: "PS", G: g, H: h, X: elgamal.Flatten(X),
+				Y: elgamal.Flatten(Y), Xbar: elgamal.Flatten(Xbar),
+				Ybar: elgamal.Flatten(Ybar), Proof: stamp, Width: width}
+			if err := BatchVerify(suite, []Instance{in}, suite.RandomStream()); err != nil {
+				t.Fatal(err)
+			}
+			in.Xbar = elgamal.Flatten(freshX)
+			in.Ybar = elgamal.Flatten(freshY)
+			if BatchVerify(suite, []Instance{in}, suite.RandomStream()) == nil {
+				t.Fatal("batch verified a column shuffled independently")
+			}
+
+			if _, err := VerifierWide(suite, g, h, X, Y, Xbar, Ybar[:2], 1); err != elgamal.ErrMismatchedLength {
+				t.Fatalf("mismatched widths: %v", err)
+			}
+			if _, _, _, err := ShuffleWide(suite, g, h, nil, nil, suite.RandomStream()); err != elgamal.ErrMismatchedLength {
+				t.Fatalf("no columns: %v", err)
+			}
+		})
+	}
+}
+
+func TestTamperedOutput(t *testing.T) {
+	suite, st, stamp, _ := shuffle(t, "Ed25519", 5)
+	stream := suite.RandomStream()
+
+	// Replace a single output pair by an encryption of a different ballot.
+	forged := st.clone()
+	forged.Xbar[2], forged.Ybar[2], _ = elgamal.Encrypt(suite, st.h, []byte("forged"), stream)
+	if verify(suite, forged, stamp) == nil {
+		t.Fatal("proof verified with a forged output pair")
+	}
+
+	// Modify only one component of an output pair.
+	forged = st.clone()
+	forged.Ybar[0] = suite.Point().Add(st.Ybar[0], st.g)
+	if verify(suite, forged, stamp) == nil {
+		t.Fatal("proof verified with a modified output ciphertext")
+	}
+
+	// Swap the first components of two output pairs.
+	forged = st.clone()
+	forged.Xbar[0], forged.Xbar[1] = st.Xbar[1], st.Xbar[0]
+	if verify(suite, forged, stamp) == nil {
+		t.Fatal("proof verified with mixed up output pairs")
+	}
+}
+
+// Offsets of the prover messages within a proof: the commitments of step 1,
+// the D vector of step 3, step 5 together with the inputs of the simple
+// k-shuffle, its Theta vector and its alpha vector.
+func messages(suite suites.Suite, k int) []int {
+	P, S := suite.PointLen(), suite.ScalarLen()
+	lengths := []int{(4*k + 3) * P, k * P, (k+1)*S + 2*k*P, 2 * k * P, (2*k - 1) * S}
+	offsets := make([]int, len(lengths)+1)
+	for i, l := range lengths {
+		offsets[i+1] = offsets[i] + l
+	}
+	return offsets
+}
+
+func TestSwappedMessages(t *testing.T) {
+	suite, st, stamp, prover := shuffle(t, "P256", 4)
+	offsets := messages(suite, 4)
+	if offsets[len(offsets)-1] != len(stamp) {
+		t.Fatalf("proof of %d bytes, expected %d", len(stamp), offsets[len(offsets)-1])
+	}
+
+	// A second proof of the same shuffle with fresh prover randomness.
+	other, err := proof.HashProve(suite, "PS", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(suite, st, other); err != nil {
+		t.Fatal(err)
+	}
+
+	// Splicing any message of one proof into the other breaks both the
+	// Fiat-Shamir challenges and the algebraic checks.
+	for m := 0; m+1 < len(offsets); m++ {
+		spliced := append([]byte(nil), stamp...)
+		copy(spliced[offsets[m]:offsets[m+1]], other[offsets[m]:offsets[m+1]])
+		if verify(suite, st, spliced) == nil {
+			t.Fatalf("proof verified with message %d of another proof", m)
+		}
+	}
+
+	// Swap two elements of the D vector within the proof.
+	P := suite.PointLen()
+	swapped := append([]byte(nil), stamp...)
+	d := offsets[1]
+	copy(swapped[d:d+P], stamp[d+P:d+2*P])
+	copy(swapped[d+P:d+2*P], stamp[d:d+P])
+	if verify(suite, st, swapped) == nil {
+		t.Fatal("proof verified with swapped D elements")
+	}
+
+	if verify(suite, st, stamp[:len(stamp)-1]) == nil {
+		t.Fatal("truncated proof verified")
+	}
+}
+
+func TestReusedProof(t *testing.T) {
+	suite, st, stamp, _ := shuffle(t, "Ed25519", 4)
+
+	// Same outputs claimed for fresh inputs.
+	reused := st.clone()
+	reused.X, reused.Y = encrypt(suite, st.h, 4)
+	if verify(suite, reused, stamp) == nil {
+		t.Fatal("proof verified for different inputs")
+	}
+
+	// Inputs and outputs under a different public key.
+	reused = st.clone()
+	reused.h = suite.Point().Pick(suite.RandomStream())
+	if verify(suite, reused, stamp) == nil {
+		t.Fatal("proof verified under a different key")
+	}
+
+	// Inputs and outputs exchanged.
+	reused = st.clone()
+	reused.X, reused.Y, reused.Xbar, reused.Ybar = st.Xbar, st.Ybar, st.X, st.Y
+	if verify(suite, reused, stamp) == nil {
+		t.Fatal("proof verified for the inverse shuffle")
+	}
+
+	// Proof of a shuffle of the same size under another seed.
+	_, _, foreign, _ := seededShuffle(t, "Ed25519", "foreign", 4)
+	if verify(suite, st, foreign) == nil {
+		t.Fatal("proof of another shuffle verified")
+	}
+}
+
+// A prover that duplicates one ballot and drops another must not convince
+// the verifier, even though it runs the honest proving algorithm.
+func TestNonPermutation(t *testing.T) {
+	suite, err := suites.Lookup("P256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+
+	k := 4
+	st := &statement{g: suite.Point().Base(), h: key.Public}
+	st.X, st.Y = encrypt(suite, key.Public, k)
+
+	pi := []int{1, 0, 2, 1}
+	beta := make([]kyber.Scalar, k)
+	st.Xbar = make([]kyber.Point, k)
+	st.Ybar = make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		beta[i] = suite.Scalar().Pick(stream)
+	}
+	for i := 0; i < k; i++ {
+		st.Xbar[i] = suite.Point().Mul(beta[pi[i]], st.g)
+		st.Xbar[i].Add(st.Xbar[i], st.X[pi[i]])
+		st.Ybar[i] = suite.Point().Mul(beta[pi[i]], st.h)
+		st.Ybar[i].Add(st.Ybar[i], st.Y[pi[i]])
+	}
+
+	ps, err := new(PairShuffle).Init(suite, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := proof.HashProve(suite, "PS", func(ctx proof.ProverContext) error {
+		return ps.Prove(pi, st.g, st.h, beta, st.X, st.Y, stream, ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verify(suite, st, stamp) == nil {
+		t.Fatal("proof of a non-permutation verified")
+	}
+}
+
+func TestMalformedInput(t *testing.T) {
+	suite, st, _, _ := shuffle(t, "P256", 3)
+
+	if _, err := Verifier(suite, st.g, st.h, st.X, st.Y, st.Xbar, st.Ybar[:2], 1); err != elgamal.ErrMismatchedLength {
+		t.Fatalf("mismatched vectors: %v", err)
+	}
+	if _, err := Verifier(suite, st.g, st.h, st.X[:1], st.Y[:1], st.Xbar[:1], st.Ybar[:1], 1); err != elgamal.ErrTooFewCiphertexts {
+		t.Fatalf("single pair: %v", err)
+	}
+	if _, _, _, err := Shuffle(suite, st.g, st.h, st.X, st.Y[:2], suite.RandomStream()); err != elgamal.ErrMismatchedLength {
+		t.Fatalf("mismatched shuffle input: %v", err)
+	}
+	if err := verify(suite, st, nil); err == nil {
+		t.Fatal("empty proof verified")
+	}
+}
+
+func (st *statement) instance(stamp []byte) Instance {
+	return Instance{Name: "PS", G: st.g, H: st.h, X: st.X, Y: st.Y, Xbar: st.Xbar,
+		Ybar: st.Ybar, Proof: stamp}
+}
+
+func TestBatchVerify(t *testing.T) {
+	for _, name := range []string{"P256", "Ed25519", "Residue512"} {
+		t.Run(name, func(t *testing.T) {
+			var suite suites.Suite
+			var instances []Instance
+			for i, k := range []int{2, 3, 5, 4} {
+				var st *statement
+				var stamp []byte
+				suite, st, stamp, _ = seededShuffle(t, name, fmt.Sprint("hop", i), k)
+				instances = append(instances, st.instance(stamp))
+			}
+			stream := suite.RandomStream()
+			if err := BatchVerify(suite, instances, stream); err != nil {
+				t.Fatal(err)
+			}
+
+			// An output pair not covered by the proof only violates the group
+			// equations, a modified proof already its challenges.
+			forged := append([]Instance(nil), instances...)
+			forged[1].Ybar = append([]kyber.Point(nil), forged[1].Ybar...)
+			forged[1].Ybar[0] = suite.Point().Add(forged[1].Ybar[0], forged[1].G)
+			forged[3].Proof = append([]byte(nil), forged[3].Proof...)
+			forged[3].Proof[len(forged[3].Proof)-1] ^= 1
+
+			err := BatchVerify(suite, forged, stream)
+			batchErr, ok := err.(*BatchError)
+			if !ok {
+				t.Fatalf("batch of forged proofs: %v", err)
+			}
+			for i, err := range batchErr.Errs {
+				if (err != nil) != (i == 1 || i == 3) {
+					t.Fatalf("proof %d: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+// A point of order 2 added to an output on Ed25519 vanishes from every
+// equation with an even weight, the batch has to reject it all the same.
+func TestBatchVerifyTorsion(t *testing.T) {
+	suite, st, stamp, _ := seededShuffle(t, "Ed25519", "torsion", 4)
+	T := suite.Point()
+	buf, _ := hex.DecodeString("ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f")
+	if err := T.UnmarshalBinary(buf); err != nil {
+		t.Fatal(err)
+	}
+	in := st.instance(stamp)
+	in.Xbar = append([]kyber.Point(nil), in.Xbar...)
+	in.Xbar[0] = suite.Point().Add(in.Xbar[0], T)
+	if verifyInstance(suite, in) == nil {
+		t.Fatal("torsion accepted individually")
+	}
+
+	stream := suite.RandomStream()
+	for i := 0; i < 40; i++ {
+		err := BatchVerify(suite, []Instance{in}, stream)
+		if batchErr, ok := err.(*BatchError); !ok || batchErr.Errs[0] == nil {
+			t.Fatalf("torsion accepted in round %d: %v", i, err)
+		}
+	}
+
+	// A batch error without an invalid proof still prints.
+	_ = (&BatchError{Errs: []error{nil}}).Error()
+}
+
+var benchSizes = []int{10, 50, 100}
+
+// Workers of the serial and the parallel benchmarks.
+func benchWorkers(parallel bool) int {
+	if parallel {
+		return runtime.NumCPU()
+	}
+	return 1
+}
+
+func BenchmarkShuffle(b *testing.B) {
+	for _, k := range benchSizes {
+		for _, parallel := range []bool{false, true} {
+			b.Run(fmt.Sprintf("k=%d/parallel=%t", k, parallel), func(b *testing.B) {
+				suite, st, _, _ := shuffle(b, "P256", k)
+				stream := suite.RandomStream()
+				ps, err := new(PairShuffle).Init(suite, k)
+				if err != nil {
+					b.Fatal(err)
+				}
+				ps.SetWorkers(benchWorkers(parallel))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _, prover, err := ps.Shuffle(st.g, st.h, st.X, st.Y, stream)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := proof.HashProve(suite, "PS", prover); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkShuffleTables(b *testing.B) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		for _, tables := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/tables=%t", name, tables), func(b *testing.B) {
+				suite, st, _, _ := shuffle(b, name, 100)
+				stream := suite.RandomStream()
+				ps, err := new(PairShuffle).Init(suite, 100)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if tables {
+					ps.SetBases(elgamal.NewBases(suite, st.g, st.h))
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _, prover, err := ps.Shuffle(st.g, st.h, st.X, st.Y, stream)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := proof.HashProve(suite, "PS", prover); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Offline preparation of a kit and the online shuffle and proof with it.
+func BenchmarkKit(b *testing.B) {
+	for _, name := range []string{"Ed25519", "P256"} {
+		suite, st, _, _ := shuffle(b, name, 100)
+		stream := suite.RandomStream()
+		ps, err := new(PairShuffle).Init(suite, 100)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ps.SetBases(elgamal.NewBases(suite, st.g, st.h))
+
+		b.Run(name+"/offline", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ps.Precompute(st.g, st.h, stream)
+			}
+		})
+		b.Run(name+"/online", func(b *testing.B) {
+			kits := make([]*Kit, b.N)
+			for i := range kits {
+				kits[i] = ps.Precompute(st.g, st.h, stream)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _, prover, err := ps.ShuffleKit(kits[i], st.X, st.Y)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := proof.HashProve(suite, "PS", prover); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// One wide shuffle and proof against separate ones for every column.
+func BenchmarkWide(b *testing.B) {
+	k := 50
+	for _, width := range []int{1, 2, 4} {
+		suite, st, _, _ := shuffle(b, "P256", k)
+		stream := suite.RandomStream()
+		X, Y := encryptWide(suite, st.h, k, width)
+
+		b.Run(fmt.Sprintf("width=%d/wide", width), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, prover, err := ShuffleWide(suite, st.g, st.h, X, Y, stream)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := proof.HashProve(suite, "PS", prover); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("width=%d/separate", width), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := range X {
+					_, _, prover, err := Shuffle(suite, st.g, st.h, X[j], Y[j], stream)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := proof.HashProve(suite, "PS", prover); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	for _, k := range benchSizes {
+		for _, parallel := range []bool{false, true} {
+			b.Run(fmt.Sprintf("k=%d/parallel=%t", k, parallel), func(b *testing.B) {
+				suite, st, stamp, _ := shuffle(b, "P256", k)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					verifier, err := Verifier(suite, st.g, st.h, st.X, st.Y, st.Xbar, st.Ybar,
+						benchWorkers(parallel))
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := proof.HashVerify(suite, "PS", verifier, stamp); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Verification of thousands of pairs, where the multi-exponentiations of the
+// verifier pay off in groups with cheap additions.
+func BenchmarkVerifyLarge(b *testing.B) {
+	for _, name := range []string{"Ed25519", "P256", "Residue512"} {
+		b.Run(fmt.Sprintf("%s/k=1000", name), func(b *testing.B) {
+			suite, st, stamp, _ := shuffle(b, name, 1000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := verify(suite, st, stamp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Batch verification of several hops in a group where the
+// multi-exponentiation pays off.
+func BenchmarkBatchVerify(b *testing.B) {
+	var suite suites.Suite
+	var instances []Instance
+	for i := 0; i < 10; i++ {
+		var st *statement
+		var stamp []byte
+		suite, st, stamp, _ = seededShuffle(b, "Ed25519", fmt.Sprint("hop", i), 100)
+		instances = append(instances, st.instance(stamp))
+	}
+
+	b.Run("individual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, in := range instances {
+				verifier, _ := Verifier(suite, in.G, in.H, in.X, in.Y, in.Xbar, in.Ybar, 1)
+				if err := proof.HashVerify(suite, "PS", verifier, in.Proof); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := BatchVerify(suite, instances, suite.RandomStream()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package neff
+
+import "sync"
+
+// Split the index range [0, k) into at most workers contiguous chunks and
+// run f on all of them concurrently. Chunk c covers [lo, hi) and the chunks
+// are ordered, so that results collected per chunk can be combined in a fixed
+// order independent of the scheduling. Returns the number of chunks.
+func each(k, workers int, f func(c, lo, hi int)) int {
+	n := chunks(k, workers)
+	if n == 1 {
+		f(0, 0, k)
+		return 1
+	}
+
+	var wg sync.WaitGroup
+	for c := 0; c < n; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			f(c, c*k/n, (c+1)*k/n)
+		}(c)
+	}
+	wg.Wait()
+
+	return n
+}
+
+// Number of chunks each splits k indices into.
+func chunks(k, workers int) int {
+	if workers > k {
+		workers = k
+	}
+	if workers < 1 {
+		return 1
+	}
+	return workers
+}
@@ -4,50 +4,66 @@ import (
 	"crypto/cipher"
 	"errors"
 
-	"gopkg.in/dedis/crypto.v0/abstract"
-	"gopkg.in/dedis/crypto.v0/proof"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/multiexp"
 )
 
 // P (Prover) step 0: public inputs to the simple k-shuffle.
 type ssa0 struct {
-	X []abstract.Point
-	Y []abstract.Point
+	X []kyber.Point
+	Y []kyber.Point
 }
 
 // V (Verifier) step 1: random challenge t
 type ssa1 struct {
-	Zt abstract.Scalar
+	Zt kyber.Scalar
 }
 
 // P step 2: Theta vectors
 type ssa2 struct {
-	Theta []abstract.Point
+	Theta []kyber.Point
 }
 
 // V step 3: random challenge c
 type ssa3 struct {
-	Zc abstract.Scalar
+	Zc kyber.Scalar
 }
 
 // P step 4: alpha vector
 type ssa4 struct {
-	Zalpha []abstract.Scalar
+	Zalpha []kyber.Scalar
 }
 
 type SimpleShuffle struct {
-	grp abstract.Group
+	grp kyber.Group
 	p0  ssa0
 	v1  ssa1
 	p2  ssa2
 	v3  ssa3
 	p4  ssa4
+
+	// Number of goroutines the O(k) loops are split across.
+	workers int
+
+	// If set, Verify adds its group equations to the batch instead of
+	// checking them.
+	batch *batch
+
+	// Fixed-base tables Prove uses if they belong to G.
+	bases *elgamal.Bases
+
+	// Secrets of step 2 drawn ahead of the next Prove, if set.
+	theta []kyber.Scalar
 }
 
 // Simple helper to compute G^{ab-cd} for Theta vector computation.
-func thenc(grp abstract.Group, G abstract.Point,
-	a, b, c, d abstract.Scalar) abstract.Point {
+func thenc(grp kyber.Group, mulG func(kyber.Scalar) kyber.Point,
+	a, b, c, d kyber.Scalar) kyber.Point {
 
-	var ab, cd abstract.Scalar
+	var ab, cd kyber.Scalar
 	if a != nil {
 		ab = grp.Scalar().Mul(a, b)
 	} else {
@@ -62,37 +78,41 @@ func thenc(grp abstract.Group, G abstract.Point,
 	} else {
 		cd = grp.Scalar().Zero()
 	}
-	return grp.Point().Mul(G, ab.Sub(ab, cd))
+	return mulG(ab.Sub(ab, cd))
 }
 
-func (ss *SimpleShuffle) Init(grp abstract.Group, k int) *SimpleShuffle {
+func (ss *SimpleShuffle) Init(grp kyber.Group, k int) *SimpleShuffle {
 	ss.grp = grp
-	ss.p0.X = make([]abstract.Point, k)
-	ss.p0.Y = make([]abstract.Point, k)
-	ss.p2.Theta = make([]abstract.Point, 2*k)
-	ss.p4.Zalpha = make([]abstract.Scalar, 2*k-1)
+	ss.p0.X = make([]kyber.Point, k)
+	ss.p0.Y = make([]kyber.Point, k)
+	ss.p2.Theta = make([]kyber.Point, 2*k)
+	ss.p4.Zalpha = make([]kyber.Scalar, 2*k-1)
 	return ss
 }
 
-func (ss *SimpleShuffle) Prove(G abstract.Point, gamma abstract.Scalar,
-	x, y []abstract.Scalar, rand cipher.Stream,
+func (ss *SimpleShuffle) Prove(G kyber.Point, gamma kyber.Scalar,
+	x, y []kyber.Scalar, rand cipher.Stream,
 	ctx proof.ProverContext) error {
 
 	grp := ss.grp
 
 	k := len(x)
 	if k <= 1 {
-		panic("can't shuffle length 1 vector")
+		return elgamal.ErrTooFewCiphertexts
 	}
-	if k != len(y) {
-		panic("mismatched vector lengths")
+	if k != len(y) || k != len(ss.p0.X) {
+		return elgamal.ErrMismatchedLength
 	}
 
+	mulG := mulBase(grp, ss.bases, G)
+
 	// Step 0: inputs
-	for i := 0; i < k; i++ { // (4)
-		ss.p0.X[i] = grp.Point().Mul(G, x[i])
-		ss.p0.Y[i] = grp.Point().Mul(G, y[i])
-	}
+	each(k, ss.workers, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ { // (4)
+			ss.p0.X[i] = mulG(x[i])
+			ss.p0.Y[i] = mulG(y[i])
+		}
+	})
 	if err := ctx.Put(ss.p0); err != nil {
 		return err
 	}
@@ -105,26 +125,38 @@ func (ss *SimpleShuffle) Prove(G abstract.Point, gamma abstract.Scalar,
 
 	// P step 2
 	gamma_t := grp.Scalar().Mul(gamma, t)
-	xhat := make([]abstract.Scalar, k)
-	yhat := make([]abstract.Scalar, k)
+	xhat := make([]kyber.Scalar, k)
+	yhat := make([]kyber.Scalar, k)
 	for i := 0; i < k; i++ { // (5) and (6) xhat,yhat vectors
 		xhat[i] = grp.Scalar().Sub(x[i], t)
 		yhat[i] = grp.Scalar().Sub(y[i], gamma_t)
 	}
 	thlen := 2*k - 1 // (7) theta and Theta vectors
-	theta := make([]abstract.Scalar, thlen)
-	ctx.PriRand(theta)
-	Theta := make([]abstract.Point, thlen+1)
-	Theta[0] = thenc(grp, G, nil, nil, theta[0], yhat[0])
-	for i := 1; i < k; i++ {
-		Theta[i] = thenc(grp, G, theta[i-1], xhat[i],
-			theta[i], yhat[i])
-	}
-	for i := k; i < thlen; i++ {
-		Theta[i] = thenc(grp, G, theta[i-1], gamma,
-			theta[i], nil)
+	theta := ss.theta
+	ss.theta = nil
+	if len(theta) != thlen {
+		theta = make([]kyber.Scalar, thlen)
+		if err := ctx.PriRand(theta); err != nil {
+			return err
+		}
 	}
-	Theta[thlen] = thenc(grp, G, theta[thlen-1], gamma, nil, nil)
+	Theta := make([]kyber.Point, thlen+1)
+	each(thlen+1, ss.workers, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			switch {
+			case i == 0:
+				Theta[0] = thenc(grp, mulG, nil, nil, theta[0], yhat[0])
+			case i < k:
+				Theta[i] = thenc(grp, mulG, theta[i-1], xhat[i],
+					theta[i], yhat[i])
+			case i < thlen:
+				Theta[i] = thenc(grp, mulG, theta[i-1], gamma,
+					theta[i], nil)
+			default:
+				Theta[thlen] = thenc(grp, mulG, theta[thlen-1], gamma, nil, nil)
+			}
+		}
+	})
 	ss.p2.Theta = Theta
 	if err := ctx.Put(ss.p2); err != nil {
 		return err
@@ -137,7 +169,7 @@ func (ss *SimpleShuffle) Prove(G abstract.Point, gamma abstract.Scalar,
 	c := ss.v3.Zc
 
 	// P step 4
-	alpha := make([]abstract.Scalar, thlen)
+	alpha := make([]kyber.Scalar, thlen)
 	runprod := grp.Scalar().Set(c)
 	for i := 0; i < k; i++ { // (8)
 		runprod.Mul(runprod, xhat[i])
@@ -159,17 +191,15 @@ func (ss *SimpleShuffle) Prove(G abstract.Point, gamma abstract.Scalar,
 }
 
 // Simple helper to verify Theta elements,
-// by checking whether A^a*B^-b = T.
-// P,Q,s are simply "scratch" abstract.Point/Scalars reused for efficiency.
-func thver(A, B, T, P, Q abstract.Point, a, b, s abstract.Scalar) bool {
-	P.Mul(A, a)
-	Q.Mul(B, s.Neg(b))
-	P.Add(P, Q)
-	return P.Equal(T)
+// by checking whether A^a*B^-b = T as a two-point multi-exponentiation.
+// s is simply a "scratch" kyber.Scalar reused for efficiency.
+func thver(grp kyber.Group, A, B, T kyber.Point, a, b, s kyber.Scalar) bool {
+	return multiexp.Mul(grp, []kyber.Scalar{a, s.Neg(b)},
+		[]kyber.Point{A, B}).Equal(T)
 }
 
 // Verifier for Neff simple k-shuffle proofs.
-func (ss *SimpleShuffle) Verify(G, Gamma abstract.Point,
+func (ss *SimpleShuffle) Verify(G, Gamma kyber.Point,
 	ctx proof.VerifierContext) error {
 
 	grp := ss.grp
@@ -208,33 +238,81 @@ func (ss *SimpleShuffle) Verify(G, Gamma abstract.Point,
 	}
 
 	// Verifier step 5
-	negt := grp.Scalar().Neg(t)
-	U := grp.Point().Mul(G, negt)
-	W := grp.Point().Mul(Gamma, negt)
-	Xhat := make([]abstract.Point, k)
-	Yhat := make([]abstract.Point, k)
-	for i := 0; i < k; i++ {
-		Xhat[i] = grp.Point().Add(X[i], U)
-		Yhat[i] = grp.Point().Add(Y[i], W)
-	}
-	P := grp.Point()
-	Q := grp.Point()
-	s := grp.Scalar()
-	good := true
-	good = good && thver(Xhat[0], Yhat[0], Theta[0], P, Q, c, alpha[0], s)
-	for i := 1; i < k; i++ {
-		good = good && thver(Xhat[i], Yhat[i], Theta[i], P, Q,
-			alpha[i-1], alpha[i], s)
+	if ss.batch != nil {
+		ss.defer5(G, Gamma, t, c)
+		return nil
 	}
-	for i := k; i < thlen; i++ {
-		good = good && thver(Gamma, G, Theta[i], P, Q,
-			alpha[i-1], alpha[i], s)
-	}
-	good = good && thver(Gamma, G, Theta[thlen], P, Q,
-		alpha[thlen-1], c, s)
-	if !good {
-		return errors.New("incorrect SimpleShuffleProof")
+	negt := grp.Scalar().Neg(t)
+	U := grp.Point().Mul(negt, G)
+	W := grp.Point().Mul(negt, Gamma)
+	// Check all Theta elements, the first k against Xhat = X+U, Yhat = Y+W.
+	good := make([]bool, chunks(thlen+1, ss.workers))
+	each(thlen+1, ss.workers, func(ch, lo, hi int) {
+		s := grp.Scalar()
+		good[ch] = true
+		for i := lo; i < hi && good[ch]; i++ {
+			A, B := Gamma, G
+			if i < k {
+				A = grp.Point().Add(X[i], U)
+				B = grp.Point().Add(Y[i], W)
+			}
+			a, b := c, c
+			if i > 0 {
+				a = alpha[i-1]
+			}
+			if i < thlen {
+				b = alpha[i]
+			}
+			good[ch] = thver(grp, A, B, Theta[i], a, b, s)
+		}
+	})
+	for _, ok := range good {
+		if !ok {
+			return errors.New("incorrect SimpleShuffleProof")
+		}
 	}
 
 	return nil
 }
+
+// Add the Theta equations of verifier step 5 to the batch. With
+// Xhat_i = X_i - tG and Yhat_i = Y_i - t Gamma all multiples of G and Gamma
+// are collected into a single term each.
+func (ss *SimpleShuffle) defer5(G, Gamma kyber.Point, t, c kyber.Scalar) {
+	grp := ss.grp
+	b := ss.batch
+	X, Y := ss.p0.X, ss.p0.Y
+	Theta := ss.p2.Theta
+	alpha := ss.p4.Zalpha
+	k := len(Y)
+	thlen := 2*k - 1
+
+	sG := grp.Scalar().Zero()
+	sGamma := grp.Scalar().Zero()
+	for i := 0; i <= thlen; i++ {
+		a, e := c, c
+		if i > 0 {
+			a = alpha[i-1]
+		}
+		if i < thlen {
+			e = alpha[i]
+		}
+
+		// r (a A - e B - Theta_i) = 0
+		r := b.weight()
+		ra := grp.Scalar().Mul(r, a)
+		re := grp.Scalar().Mul(r, e)
+		if i < k {
+			b.term(ra, X[i])
+			b.term(grp.Scalar().Neg(re), Y[i])
+			sG.Sub(sG, grp.Scalar().Mul(ra, t))
+			sGamma.Add(sGamma, grp.Scalar().Mul(re, t))
+		} else {
+			sGamma.Add(sGamma, ra)
+			sG.Sub(sG, re)
+		}
+		b.term(grp.Scalar().Neg(r), Theta[i])
+	}
+	b.term(sG, G)
+	b.term(sGamma, Gamma)
+}
@@ -0,0 +1,85 @@
+/*
+Package permutation samples uniformly random permutations and provides the
+inversion and composition shuffle proofs need. A permutation of k elements is
+an []int holding every index of [0, k) exactly once, pi[i] is the image of i.
+*/
+package permutation
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+// Permutations of different lengths cannot be composed.
+var ErrMismatchedLength = errors.New("mismatched permutation lengths")
+
+// Uniformly random permutation of k indices drawn with Fisher-Yates.
+func Random(k int, stream cipher.Stream) []int {
+	pi := Identity(k)
+	for i := k - 1; i > 0; i-- {
+		j := Uniform(i+1, stream)
+		pi[i], pi[j] = pi[j], pi[i]
+	}
+	return pi
+}
+
+// Uniformly random index of [0, n). 64-bit draws below 2^64 mod n are
+// rejected, so that every index has the same probability.
+func Uniform(n int, stream cipher.Stream) int {
+	if n <= 0 {
+		panic("permutation: non-positive range")
+	}
+	m := uint64(n)
+	limit := -m % m // 2^64 mod n
+	for {
+		x := binary.BigEndian.Uint64(random.Bits(64, false, stream))
+		if x >= limit {
+			return int(x % m)
+		}
+	}
+}
+
+// Identity permutation of k indices.
+func Identity(k int) []int {
+	pi := make([]int, k)
+	for i := range pi {
+		pi[i] = i
+	}
+	return pi
+}
+
+// Whether pi holds every index of [0, len(pi)) exactly once.
+func Valid(pi []int) bool {
+	seen := make([]bool, len(pi))
+	for _, j := range pi {
+		if j < 0 || j >= len(pi) || seen[j] {
+			return false
+		}
+		seen[j] = true
+	}
+	return true
+}
+
+// Inverse of the permutation pi.
+func Inverse(pi []int) []int {
+	inv := make([]int, len(pi))
+	for i, j := range pi {
+		inv[j] = i
+	}
+	return inv
+}
+
+// Composition pi o sigma, mapping i to pi[sigma[i]].
+func Compose(pi, sigma []int) ([]int, error) {
+	if len(pi) != len(sigma) {
+		return nil, ErrMismatchedLength
+	}
+	c := make([]int, len(sigma))
+	for i, j := range sigma {
+		c[i] = pi[j]
+	}
+	return c, nil
+}
@@ -0,0 +1,148 @@
+package permutation
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func seeded(t *testing.T) cipher.Stream {
+	suite, _ := suites.Lookup(suites.Default)
+	return suites.Seeded(suite, []byte(t.Name())).RandomStream()
+}
+
+// Pearson's chi-squared statistic of the counts against a uniform
+// distribution.
+func chiSquared(counts map[string]int, cells, n int) float64 {
+	expected := float64(n) / float64(cells)
+	chi := 0.0
+	for _, c := range counts {
+		d := float64(c) - expected
+		chi += d * d / expected
+	}
+	// Cells that were never hit.
+	chi += float64(cells-len(counts)) * expected
+	return chi
+}
+
+// Every permutation of k = 3 and 4 elements is drawn equally often. The
+// thresholds are the 99.9% quantiles of the chi-squared distribution with 5
+// and 23 degrees of freedom.
+func TestUniformity(t *testing.T) {
+	for _, c := range []struct {
+		k, cells int
+		limit    float64
+	}{{3, 6, 20.52}, {4, 24, 49.73}} {
+		t.Run(fmt.Sprint(c.k), func(t *testing.T) {
+			stream := seeded(t)
+			n := 2000 * c.cells
+			counts := make(map[string]int)
+			for i := 0; i < n; i++ {
+				pi := Random(c.k, stream)
+				if !Valid(pi) {
+					t.Fatalf("%v is not a permutation", pi)
+				}
+				counts[fmt.Sprint(pi)]++
+			}
+			if len(counts) != c.cells {
+				t.Fatalf("%d of %d permutations drawn", len(counts), c.cells)
+			}
+			if chi := chiSquared(counts, c.cells, n); chi > c.limit {
+				t.Fatalf("chi-squared %.2f exceeds %.2f", chi, c.limit)
+			}
+		})
+	}
+}
+
+// Every index of [0, 7) is drawn equally often, the threshold is the 99.9%
+// quantile for 6 degrees of freedom.
+func TestUniform(t *testing.T) {
+	stream := seeded(t)
+	n := 7 * 5000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		j := Uniform(7, stream)
+		if j < 0 || j >= 7 {
+			t.Fatalf("index %d out of range", j)
+		}
+		counts[fmt.Sprint(j)]++
+	}
+	if chi := chiSquared(counts, 7, n); chi > 22.46 {
+		t.Fatalf("chi-squared %.2f exceeds 22.46", chi)
+	}
+}
+
+// Stream yielding the given 64-bit values one after the other.
+type values []uint64
+
+func (v *values) XORKeyStream(dst, src []byte) {
+	binary.BigEndian.PutUint64(dst, (*v)[0])
+	*v = (*v)[1:]
+}
+
+// Draws from the incomplete last block of 2^64 mod n values are rejected.
+func TestRejection(t *testing.T) {
+	n := 3 << 61 // 2^64 mod n = 2^62
+	stream := &values{0, 1<<62 - 1, 1 << 62}
+	if j := Uniform(n, stream); j != 1<<62 || len(*stream) != 0 {
+		t.Fatalf("got %d with %d draws left", j, len(*stream))
+	}
+
+	stream = &values{5}
+	if j := Uniform(3, stream); j != 2 {
+		t.Fatalf("got %d", j)
+	}
+}
+
+func TestInverseCompose(t *testing.T) {
+	stream := seeded(t)
+	for k := 0; k <= 10; k++ {
+		pi := Random(k, stream)
+		sigma := Random(k, stream)
+		inv := Inverse(pi)
+		if !Valid(inv) {
+			t.Fatalf("inverse %v is not a permutation", inv)
+		}
+
+		for _, id := range [][]int{compose(t, pi, inv), compose(t, inv, pi)} {
+			if fmt.Sprint(id) != fmt.Sprint(Identity(k)) {
+				t.Fatalf("%v composed with its inverse is %v", pi, id)
+			}
+		}
+
+		c := compose(t, pi, sigma)
+		for i := range c {
+			if c[i] != pi[sigma[i]] {
+				t.Fatalf("composition %v of %v and %v", c, pi, sigma)
+			}
+		}
+	}
+
+	if _, err := Compose([]int{0}, []int{0, 1}); err != ErrMismatchedLength {
+		t.Fatalf("mismatched permutations: %v", err)
+	}
+}
+
+func compose(t *testing.T, pi, sigma []int) []int {
+	c, err := Compose(pi, sigma)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestValid(t *testing.T) {
+	for _, pi := range [][]int{{}, {0}, {2, 0, 1}} {
+		if !Valid(pi) {
+			t.Fatalf("%v rejected", pi)
+		}
+	}
+	for _, pi := range [][]int{{1}, {0, 0}, {-1, 0}, {0, 2}} {
+		if Valid(pi) {
+			t.Fatalf("%v accepted", pi)
+		}
+	}
+}
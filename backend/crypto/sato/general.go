@@ -2,100 +2,206 @@ package sato
 
 import (
 	"crypto/cipher"
+	"errors"
+	"sync"
 
-	"gopkg.in/dedis/crypto.v0/abstract"
-	"gopkg.in/dedis/crypto.v0/proof"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
 
 	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/permutation"
+	"github.com/qantik/evo/backend/crypto/suites"
 )
 
+// Number of rounds giving a soundness error of 2^-80.
+const DefaultRounds = 80
+
+// Upper bound on the number of rounds. The prover and verifier allocate the
+// shadow shuffles of all rounds up front, more than 2^-256 soundness is never
+// needed.
+const MaxRounds = 256
+
+var (
+	// A Sako-Kilian proof needs at least one round.
+	ErrNoRounds = errors.New("no Sako-Kilian rounds")
+	// The number of rounds exceeds MaxRounds.
+	ErrTooManyRounds = errors.New("too many Sako-Kilian rounds")
+)
+
+// P (Prover) step 1: shadow shuffle commitments, one per round, column by
+// column
 type sigma1 struct {
-	U []abstract.Point
-	V []abstract.Point
+	U [][]kyber.Point
+	V [][]kyber.Point
 }
 
+// V (Verifier) step 2: one challenge bit per round
 type sigma2 struct {
-	Mask abstract.Scalar
+	Mask []byte
 }
 
+// P step 3: shadow shuffle openings, one per round, the blinding factors
+// column by column
 type sigma3 struct {
 	Lambda []int
-	Gamma  []abstract.Scalar
+	Gamma  [][]kyber.Scalar
 }
 
 type Protocol struct {
-	group     abstract.Group
+	group     kyber.Group
 	k         int
-	prover1   sigma1
+	width     int
+	rounds    int
+	prover1   []sigma1
 	verifier2 sigma2
-	prover3   sigma3
+	prover3   []sigma3
 }
 
-func (protocol *Protocol) init(group abstract.Group, k int) {
+func (protocol *Protocol) init(group kyber.Group, k, width, rounds int) {
 	protocol.group = group
 	protocol.k = k
-	protocol.prover1.U = make([]abstract.Point, k)
-	protocol.prover1.V = make([]abstract.Point, k)
-	protocol.verifier2.Mask = group.Scalar()
-	protocol.prover3.Lambda = make([]int, k)
-	protocol.prover3.Gamma = make([]abstract.Scalar, k)
+	protocol.width = width
+	protocol.rounds = rounds
+	protocol.prover1 = make([]sigma1, rounds)
+	protocol.prover3 = make([]sigma3, rounds)
+	for r := 0; r < rounds; r++ {
+		p1, p3 := &protocol.prover1[r], &protocol.prover3[r]
+		p1.U = make([][]kyber.Point, width)
+		p1.V = make([][]kyber.Point, width)
+		p3.Lambda = make([]int, k)
+		p3.Gamma = make([][]kyber.Scalar, width)
+		for j := 0; j < width; j++ {
+			p1.U[j] = make([]kyber.Point, k)
+			p1.V[j] = make([]kyber.Point, k)
+			p3.Gamma[j] = make([]kyber.Scalar, k)
+		}
+	}
+	protocol.verifier2.Mask = make([]byte, (rounds+7)/8)
 }
 
-func (protocol *Protocol) prove(pi []int, g, w abstract.Point, beta []abstract.Scalar,
-	A, B []abstract.Point, stream cipher.Stream, context proof.ProverContext) error {
-
-	k := len(pi)
-	U, V, lambda, gamma := elgamal.Permute(protocol.group, g, w, A, B, stream)
+// Challenge bit of round r.
+func (protocol *Protocol) bit(r int) byte {
+	return (protocol.verifier2.Mask[r/8] >> uint(r%8)) & 1
+}
 
-	piInv := make([]int, k)
-	for i := 0; i < k; i++ {
-		piInv[pi[i]] = i
+func (protocol *Protocol) prove(pi []int, g, w kyber.Point,
+	beta [][]kyber.Scalar, A, B [][]kyber.Point, parallel bool, stream cipher.Stream,
+	context proof.ProverContext) error {
+
+	grp := protocol.group
+	k := protocol.k
+
+	piInv := permutation.Inverse(pi)
+
+	// Commit to all shadow shuffles before asking for the challenge so that
+	// the rounds cannot be ground one bit at a time. Every round draws from
+	// its own stream, the shadow shuffles are the same whether or not they
+	// are computed concurrently. All columns share the shadow permutation.
+	lambda := make([][]int, protocol.rounds)
+	gamma := make([][][]kyber.Scalar, protocol.rounds)
+	streams := suites.Split(stream, protocol.rounds)
+	errs := make([]error, protocol.rounds)
+	shadow := func(r int) {
+		p1 := &protocol.prover1[r]
+		p1.U, p1.V, lambda[r], gamma[r], errs[r] = elgamal.PermuteWide(grp, g, w,
+			A, B, streams[r])
 	}
-
-	lambdaPrime := make([]int, k)
-	for i := 0; i < k; i++ {
-		lambdaPrime[i] = lambdaPrime[piInv[i]]
+	if parallel {
+		var wg sync.WaitGroup
+		for r := 0; r < protocol.rounds; r++ {
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				shadow(r)
+			}(r)
+		}
+		wg.Wait()
+	} else {
+		for r := 0; r < protocol.rounds; r++ {
+			shadow(r)
+		}
 	}
-
-	gammaPrime := make([]abstract.Scalar, k)
-	for i := 0; i < k; i++ {
-		gammaPrime[i] = protocol.group.Scalar().Sub(gamma[i], beta[i])
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
 	}
-
-	protocol.prover1.U = U
-	protocol.prover1.V = V
 	if err := context.Put(protocol.prover1); err != nil {
 		return err
 	}
 
-	if err := context.PubRand(protocol.verifier2); err != nil {
+	if err := context.PubRand(&protocol.verifier2); err != nil {
 		return err
 	}
 
-	if protocol.verifier2.Mask.Bytes()[0]%2 == 0 {
-		protocol.prover3.Lambda = lambda
-		protocol.prover3.Gamma = gamma
+	for r := 0; r < protocol.rounds; r++ {
+		p3 := &protocol.prover3[r]
+		if protocol.bit(r) == 0 {
+			// Open the shadow shuffle against the input.
+			p3.Lambda = lambda[r]
+			p3.Gamma = gamma[r]
+			continue
+		}
 
-	} else {
-		protocol.prover3.Lambda = lambdaPrime
-		protocol.prover3.Gamma = gammaPrime
+		// Open the shadow shuffle against the output: lambda' = pi^-1 o lambda
+		// and the blinding factors with the ones of the real shuffle removed.
+		p3.Lambda, _ = permutation.Compose(piInv, lambda[r])
+		for j := range beta {
+			for i := 0; i < k; i++ {
+				p3.Gamma[j][i] = grp.Scalar().Sub(gamma[r][j][pi[i]], beta[j][pi[i]])
+			}
+		}
 	}
 
-	if err := context.Put(protocol.prover3); err != nil {
-		return err
+	return context.Put(protocol.prover3)
+}
+
+// Check a single round by reapplying the opened shuffle to either the
+// input or the output vectors.
+func (protocol *Protocol) check(r int, g, w kyber.Point, A, B, S, T [][]kyber.Point) bool {
+	grp := protocol.group
+	k := protocol.k
+
+	C, D := A, B
+	if protocol.bit(r) == 1 {
+		C, D = S, T
 	}
 
-	return nil
+	lambda := protocol.prover3[r].Lambda
+	gamma := protocol.prover3[r].Gamma
+	U := protocol.prover1[r].U
+	V := protocol.prover1[r].V
+
+	// lambda has to be a permutation of [0, k).
+	if len(lambda) != k || !permutation.Valid(lambda) {
+		return false
+	}
+
+	alpha := grp.Point()
+	beta := grp.Point()
+	for j := range C {
+		for i := 0; i < k; i++ {
+			alpha.Mul(gamma[j][lambda[i]], g)
+			alpha.Add(alpha, C[j][lambda[i]])
+			beta.Mul(gamma[j][lambda[i]], w)
+			beta.Add(beta, D[j][lambda[i]])
+			if !alpha.Equal(U[j][i]) || !beta.Equal(V[j][i]) {
+				return false
+			}
+		}
+	}
+
+	return true
 }
 
-func (protocol *Protocol) verify(g, w abstract.Point, A, B, S, T []abstract.Point,
-	context proof.VerifierContext) error {
+func (protocol *Protocol) verify(g, w kyber.Point, A, B, S, T [][]kyber.Point,
+	parallel bool, context proof.VerifierContext) error {
 
 	if err := context.Get(protocol.prover1); err != nil {
 		return err
 	}
 
-	if err := context.PubRand(protocol.verifier2); err != nil {
+	if err := context.PubRand(&protocol.verifier2); err != nil {
 		return err
 	}
 
@@ -103,68 +209,135 @@ func (protocol *Protocol) verify(g, w abstract.Point, A, B, S, T []abstract.Poin
 		return err
 	}
 
-	var C []abstract.Point
-	var D []abstract.Point
-
-	if protocol.verifier2.Mask.Bytes()[0]%2 == 0 {
-		C = A
-		D = B
+	good := make([]bool, protocol.rounds)
+	if parallel {
+		var wg sync.WaitGroup
+		for r := 0; r < protocol.rounds; r++ {
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				good[r] = protocol.check(r, g, w, A, B, S, T)
+			}(r)
+		}
+		wg.Wait()
 	} else {
-		C = S
-		D = T
-	}
-
-	// Verification
-	lambda := protocol.prover3.Lambda
-	gamma := protocol.prover3.Gamma
-
-	for i := 0; i < protocol.k; i++ {
-		alpha := protocol.group.Point().Mul(g, gamma[lambda[i]])
-		alpha.Add(alpha, C[lambda[i]])
-		if !alpha.Equal(protocol.prover1.U[i]) {
-			// TODO: Second half shuffle sometimes fails
+		for r := 0; r < protocol.rounds; r++ {
+			good[r] = protocol.check(r, g, w, A, B, S, T)
 		}
+	}
 
-		beta := protocol.group.Point().Mul(w, gamma[lambda[i]])
-		beta.Add(beta, D[lambda[i]])
-		if !beta.Equal(protocol.prover1.V[i]) {
-			// TODO: Second half shuffle sometimes fails
+	for r := 0; r < protocol.rounds; r++ {
+		if !good[r] {
+			return errors.New("invalid SakoKilianProof")
 		}
 	}
 
 	return nil
 }
 
-func Shuffle(group abstract.Group, g, w abstract.Point, A, B []abstract.Point,
-	stream cipher.Stream) (S, T []abstract.Point, prover proof.Prover) {
+// Shuffle the ElGamal pairs (A, B) and return a prover for a Sako-Kilian
+// proof of the shuffle running the given number of rounds. If parallel is set
+// the shadow shuffles of the rounds are computed concurrently.
+func Shuffle(group kyber.Group, g, w kyber.Point, A, B []kyber.Point,
+	rounds int, parallel bool, stream cipher.Stream) (S, T []kyber.Point,
+	prover proof.Prover, err error) {
+
+	if len(A) != len(B) {
+		return nil, nil, nil, elgamal.ErrMismatchedLength
+	}
 
-	if len(A) != len(B) || len(A) <= 1 {
-		panic("Invalid vector sizes.")
+	SS, TT, prover, err := ShuffleWide(group, g, w, [][]kyber.Point{A},
+		[][]kyber.Point{B}, rounds, parallel, stream)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return SS[0], TT[0], prover, nil
+}
+
+// Shuffle the rows of wide ciphertexts given column by column, see
+// elgamal.PermuteWide. Every round opens a single shadow permutation for all
+// columns. A proof of width one is the same as that of Shuffle.
+func ShuffleWide(group kyber.Group, g, w kyber.Point, A, B [][]kyber.Point,
+	rounds int, parallel bool, stream cipher.Stream) (S, T [][]kyber.Point,
+	prover proof.Prover, err error) {
+
+	if !rectangular(A, B) {
+		return nil, nil, nil, elgamal.ErrMismatchedLength
+	}
+	if len(A[0]) <= 1 {
+		return nil, nil, nil, elgamal.ErrTooFewCiphertexts
+	}
+	if rounds <= 0 {
+		return nil, nil, nil, ErrNoRounds
+	}
+	if rounds > MaxRounds {
+		return nil, nil, nil, ErrTooManyRounds
 	}
 
 	protocol := Protocol{}
-	protocol.init(group, len(A))
+	protocol.init(group, len(A[0]), len(A), rounds)
 
-	S, T, pi, beta := elgamal.Permute(group, g, w, A, B, stream)
+	S, T, pi, beta, err := elgamal.PermuteWide(group, g, w, A, B, stream)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	prover = func(context proof.ProverContext) error {
-		return protocol.prove(pi, g, w, beta, A, B, stream, context)
+		return protocol.prove(pi, g, w, beta, A, B, parallel, stream, context)
 	}
 
 	return
 }
 
-func Verifier(group abstract.Group, g, w abstract.Point,
-	A, B, S, T []abstract.Point) proof.Verifier {
+// Whether the wide pair vectors are non-empty and all of the same width and
+// length.
+func rectangular(V ...[][]kyber.Point) bool {
+	if len(V[0]) == 0 {
+		return false
+	}
+	for _, columns := range V {
+		if len(columns) != len(V[0]) {
+			return false
+		}
+		for _, column := range columns {
+			if len(column) != len(V[0][0]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Verifier for Sako-Kilian proofs produced by Shuffle with the same number
+// of rounds. If parallel is set the rounds are checked concurrently.
+func Verifier(group kyber.Group, g, w kyber.Point,
+	A, B, S, T []kyber.Point, rounds int, parallel bool) (proof.Verifier, error) {
 
-	k := len(A)
-	if k <= 1 || k != len(B) || k != len(S) || k != len(T) {
-		panic("Invalid vector sizes.")
+	return VerifierWide(group, g, w, [][]kyber.Point{A}, [][]kyber.Point{B},
+		[][]kyber.Point{S}, [][]kyber.Point{T}, rounds, parallel)
+}
+
+// Verifier for Sako-Kilian proofs produced by ShuffleWide.
+func VerifierWide(group kyber.Group, g, w kyber.Point,
+	A, B, S, T [][]kyber.Point, rounds int, parallel bool) (proof.Verifier,
+	error) {
+
+	if !rectangular(A, B, S, T) {
+		return nil, elgamal.ErrMismatchedLength
+	}
+	if len(A[0]) <= 1 {
+		return nil, elgamal.ErrTooFewCiphertexts
+	}
+	if rounds <= 0 {
+		return nil, ErrNoRounds
+	}
+	if rounds > MaxRounds {
+		return nil, ErrTooManyRounds
 	}
 
 	protocol := Protocol{}
-	protocol.init(group, len(A))
+	protocol.init(group, len(A[0]), len(A), rounds)
 
 	return func(context proof.VerifierContext) error {
-		return protocol.verify(g, w, A, B, S, T, context)
-	}
+		return protocol.verify(g, w, A, B, S, T, parallel, context)
+	}, nil
 }
@@ -0,0 +1,291 @@
+package sato
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+const rounds = 16
+
+// Encrypt k ballots in the named suite and shuffle them with a proof of the
+// given number of rounds.
+func shuffle(t testing.TB, name string, k int) (suite suites.Suite,
+	g, w kyber.Point, A, B, S, T []kyber.Point, stamp []byte) {
+
+	suite, err := suites.Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+	g, w = suite.Point().Base(), key.Public
+
+	A, B = encrypt(suite, w, k)
+	S, T, prover, err := Shuffle(suite, g, w, A, B, rounds, false, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stamp, err = proof.HashProve(suite, "SK", prover); err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func encrypt(suite suites.Suite, w kyber.Point, k int) (A, B []kyber.Point) {
+	stream := suite.RandomStream()
+	A = make([]kyber.Point, k)
+	B = make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		A[i], B[i], _ = elgamal.Encrypt(suite, w, []byte(fmt.Sprintf("vote#%d", i)), stream)
+	}
+	return
+}
+
+func verify(suite suites.Suite, g, w kyber.Point, A, B, S, T []kyber.Point,
+	stamp []byte, parallel bool) error {
+
+	verifier, err := Verifier(suite, g, w, A, B, S, T, rounds, parallel)
+	if err != nil {
+		return err
+	}
+	return proof.HashVerify(suite, "SK", verifier, stamp)
+}
+
+func TestCompleteness(t *testing.T) {
+	for _, name := range []string{"P256", "Ed25519"} {
+		for _, k := range []int{2, 5} {
+			t.Run(fmt.Sprintf("%s/k=%d", name, k), func(t *testing.T) {
+				suite, g, w, A, B, S, T, stamp := shuffle(t, name, k)
+				for _, parallel := range []bool{false, true} {
+					if err := verify(suite, g, w, A, B, S, T, stamp, parallel); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSoundness(t *testing.T) {
+	suite, g, w, A, B, S, T, stamp := shuffle(t, "Ed25519", 4)
+
+	forged := append([]kyber.Point(nil), T...)
+	forged[1] = suite.Point().Add(T[1], g)
+	if verify(suite, g, w, A, B, S, forged, stamp, false) == nil {
+		t.Fatal("proof verified with a modified output ciphertext")
+	}
+
+	A2, B2 := encrypt(suite, w, 4)
+	if verify(suite, g, w, A2, B2, S, T, stamp, false) == nil {
+		t.Fatal("proof verified for different inputs")
+	}
+
+	if verify(suite, g, w, A, B, S, T, stamp[:len(stamp)-1], false) == nil {
+		t.Fatal("truncated proof verified")
+	}
+
+	// A proof only verifies with the number of rounds it was made for.
+	verifier, err := Verifier(suite, g, w, A, B, S, T, rounds+8, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.HashVerify(suite, "SK", verifier, stamp) == nil {
+		t.Fatal("proof verified with a different number of rounds")
+	}
+}
+
+// A prover that duplicates one ballot and drops another is caught in every
+// round opened against the output.
+func TestNonPermutation(t *testing.T) {
+	suite, err := suites.Lookup("P256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+	g, w := suite.Point().Base(), key.Public
+
+	k := 3
+	A, B := encrypt(suite, w, k)
+	pi := []int{0, 0, 2}
+	beta := make([]kyber.Scalar, k)
+	S := make([]kyber.Point, k)
+	T := make([]kyber.Point, k)
+	for i := 0; i < k; i++ {
+		beta[i] = suite.Scalar().Pick(stream)
+	}
+	for i := 0; i < k; i++ {
+		S[i] = suite.Point().Mul(beta[pi[i]], g)
+		S[i].Add(S[i], A[pi[i]])
+		T[i] = suite.Point().Mul(beta[pi[i]], w)
+		T[i].Add(T[i], B[pi[i]])
+	}
+
+	protocol := Protocol{}
+	protocol.init(suite, k, 1, rounds)
+	stamp, err := proof.HashProve(suite, "SK", func(ctx proof.ProverContext) error {
+		return protocol.prove(pi, g, w, [][]kyber.Scalar{beta}, [][]kyber.Point{A},
+			[][]kyber.Point{B}, false, stream, ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verify(suite, g, w, A, B, S, T, stamp, false) == nil {
+		t.Fatal("proof of a non-permutation verified")
+	}
+}
+
+// The parallel prover gives the same proof as the sequential one.
+func TestParallel(t *testing.T) {
+	suite, g, w, A, B, S, _, stamp := shuffle(t, "Ed25519", 10)
+
+	seeded, _ := suites.Lookup("Ed25519")
+	seeded = suites.Seeded(seeded, []byte(t.Name()))
+	stream := seeded.RandomStream()
+	elgamal.GenerateKey(seeded, stream)
+	encrypt(seeded, w, 10)
+	Sp, Tp, prover, err := Shuffle(seeded, g, w, A, B, rounds, true, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := proof.HashProve(seeded, "SK", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parallel, stamp) || !Sp[0].Equal(S[0]) {
+		t.Fatal("parallel prover produced a different proof")
+	}
+	if err := verify(suite, g, w, A, B, Sp, Tp, parallel, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// All columns of wide ciphertexts are opened with one shadow permutation per
+// round, a column permuted differently is caught.
+func TestWide(t *testing.T) {
+	suite, err := suites.Lookup("Ed25519")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite = suites.Seeded(suite, []byte(t.Name()))
+	stream := suite.RandomStream()
+	g, w := suite.Point().Base(), elgamal.GenerateKey(suite, stream).Public
+
+	k, width := 4, 3
+	A := make([][]kyber.Point, width)
+	B := make([][]kyber.Point, width)
+	for j := range A {
+		A[j], B[j] = encrypt(suite, w, k)
+	}
+	S, T, prover, err := ShuffleWide(suite, g, w, A, B, rounds, true, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := proof.HashProve(suite, "SK", prover)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(S [][]kyber.Point) error {
+		verifier, err := VerifierWide(suite, g, w, A, B, S, T, rounds, false)
+		if err != nil {
+			return err
+		}
+		return proof.HashVerify(suite, "SK", verifier, stamp)
+	}
+	if err := verify(S); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := append([][]kyber.Point(nil), S...)
+	forged[2] = append([]kyber.Point(nil), S[2]...)
+	forged[2][0], forged[2][3] = S[2][3], S[2][0]
+	if verify(forged) == nil {
+		t.Fatal("proof verified with a column permuted differently")
+	}
+	if err := verify(S[:2]); err != elgamal.ErrMismatchedLength {
+		t.Fatalf("missing column: %v", err)
+	}
+}
+
+func TestMalformedInput(t *testing.T) {
+	suite, g, w, A, B, S, T, _ := shuffle(t, "P256", 2)
+	stream := suite.RandomStream()
+
+	if _, _, _, err := Shuffle(suite, g, w, A, B, 0, false, stream); err != ErrNoRounds {
+		t.Fatalf("zero rounds: %v", err)
+	}
+	if _, err := Verifier(suite, g, w, A, B, S, T, MaxRounds+1, false); err != ErrTooManyRounds {
+		t.Fatalf("excess rounds: %v", err)
+	}
+	if _, _, _, err := Shuffle(suite, g, w, A[:1], B[:1], rounds, false, stream); err != elgamal.ErrTooFewCiphertexts {
+		t.Fatalf("single pair: %v", err)
+	}
+	if _, err := Verifier(suite, g, w, A, B, S, T[:1], rounds, false); err != elgamal.ErrMismatchedLength {
+		t.Fatalf("mismatched vectors: %v", err)
+	}
+}
+
+var benchSizes = []int{10, 50, 100}
+
+// Benchmarks run the default number of rounds instead of the reduced one
+// of the tests.
+func BenchmarkShuffle(b *testing.B) {
+	for _, k := range benchSizes {
+		for _, parallel := range []bool{false, true} {
+			b.Run(fmt.Sprintf("k=%d/parallel=%t", k, parallel), func(b *testing.B) {
+				suite, g, w, A, B, _, _, _ := shuffle(b, "P256", k)
+				stream := suite.RandomStream()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, _, prover, err := Shuffle(suite, g, w, A, B, DefaultRounds, parallel, stream)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := proof.HashProve(suite, "SK", prover); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	for _, k := range benchSizes {
+		for _, parallel := range []bool{false, true} {
+			b.Run(fmt.Sprintf("k=%d/parallel=%t", k, parallel), func(b *testing.B) {
+				suite, g, w, A, B, _, _, _ := shuffle(b, "P256", k)
+				S, T, prover, err := Shuffle(suite, g, w, A, B, DefaultRounds, false,
+					suite.RandomStream())
+				if err != nil {
+					b.Fatal(err)
+				}
+				stamp, err := proof.HashProve(suite, "SK", prover)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					verifier, err := Verifier(suite, g, w, A, B, S, T, DefaultRounds, parallel)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := proof.HashVerify(suite, "SK", verifier, stamp); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package suites
+
+import (
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"io"
+	"math/big"
+	"reflect"
+
+	"go.dedis.ch/fixbuf"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/mod"
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
+)
+
+// Point on a NIST curve with a = -3 over a prime p = 3 mod 4, the point at
+// infinity is represented by (0, 0) as in Go's elliptic package.
+//
+// The kyber.Point interface has no room for errors, so arithmetic with a
+// point of another group marks the result as invalid instead of panicking.
+// Invalid points are not Valid, equal to no point and fail to encode, and
+// every result computed from them is invalid as well.
+type curvePoint struct {
+	x, y    *big.Int
+	c       *curve
+	invalid bool
+}
+
+func (p *curvePoint) String() string {
+	if p.invalid {
+		return "(invalid)"
+	}
+	return "(" + p.x.String() + "," + p.y.String() + ")"
+}
+
+// Point of this curve, possibly of another instance of the suite, or nil if
+// q belongs to another group or is invalid.
+func (c *curve) own(q kyber.Point) *curvePoint {
+	cq, ok := q.(*curvePoint)
+	if !ok || cq.invalid || cq.c.p != c.p {
+		return nil
+	}
+	return cq
+}
+
+// Mark p as the invalid result of arithmetic with a foreign point.
+func (p *curvePoint) fail() kyber.Point {
+	p.Null()
+	p.invalid = true
+	return p
+}
+
+func (p *curvePoint) Equal(p2 kyber.Point) bool {
+	cp2 := p.c.own(p2)
+	if p.invalid || cp2 == nil {
+		return false
+	}
+	return p.x.Cmp(cp2.x) == 0 && p.y.Cmp(cp2.y) == 0
+}
+
+func (p *curvePoint) Null() kyber.Point {
+	p.x = new(big.Int)
+	p.y = new(big.Int)
+	p.invalid = false
+	return p
+}
+
+func (p *curvePoint) Base() kyber.Point {
+	p.x = p.c.p.Gx
+	p.y = p.c.p.Gy
+	p.invalid = false
+	return p
+}
+
+func (p *curvePoint) infinity() bool {
+	return p.x.Sign() == 0 && p.y.Sign() == 0
+}
+
+func (p *curvePoint) Valid() bool {
+	return !p.invalid && (p.infinity() || p.c.IsOnCurve(p.x, p.y))
+}
+
+// Try to complete x to a point on the curve with a random sign of y.
+func (p *curvePoint) genPoint(x *big.Int, rand cipher.Stream) bool {
+	P := p.c.p.P
+	if x.Cmp(P) >= 0 {
+		return false
+	}
+
+	// y^2 = x^3 - 3x + b
+	y2 := new(big.Int).Mul(x, x)
+	y2.Mul(y2, x)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y2.Sub(y2, threeX)
+	y2.Add(y2, p.c.p.B)
+	y2.Mod(y2, P)
+
+	y := new(big.Int).ModSqrt(y2, P)
+	if y == nil {
+		return false
+	}
+	b := random.Bits(8, false, rand)
+	if b[0]&1 != 0 {
+		y.Sub(P, y)
+	}
+
+	p.x = x
+	p.y = y
+	p.invalid = false
+	return true
+}
+
+func (p *curvePoint) EmbedLen() int {
+	// Reserve the 8 most significant bits for randomness and the 8 least
+	// significant bits for the length of the embedded data.
+	return (p.c.p.P.BitLen() - 8 - 8) / 8
+}
+
+func (p *curvePoint) Pick(rand cipher.Stream) kyber.Point {
+	return p.Embed(nil, rand)
+}
+
+func (p *curvePoint) Embed(data []byte, rand cipher.Stream) kyber.Point {
+	l := p.c.coordLen()
+	dl := p.EmbedLen()
+	if dl > len(data) {
+		dl = len(data)
+	}
+
+	for {
+		b := random.Bits(uint(p.c.p.P.BitLen()), false, rand)
+		if data != nil {
+			b[l-1] = byte(dl)
+			copy(b[l-dl-1:l-1], data)
+		}
+		if p.genPoint(new(big.Int).SetBytes(b), rand) {
+			return p
+		}
+	}
+}
+
+func (p *curvePoint) Data() ([]byte, error) {
+	if p.invalid {
+		return nil, errors.New("invalid elliptic curve point")
+	}
+	b := p.x.Bytes()
+	l := p.c.coordLen()
+	if len(b) < l {
+		b = append(make([]byte, l-len(b)), b...)
+	}
+	dl := int(b[l-1])
+	if dl > p.EmbedLen() {
+		return nil, errors.New("invalid embedded data length")
+	}
+	return b[l-dl-1 : l-1], nil
+}
+
+func (p *curvePoint) Add(a, b kyber.Point) kyber.Point {
+	ca, cb := p.c.own(a), p.c.own(b)
+	if ca == nil || cb == nil {
+		return p.fail()
+	}
+	p.x, p.y = p.c.Add(ca.x, ca.y, cb.x, cb.y)
+	return p
+}
+
+func (p *curvePoint) Sub(a, b kyber.Point) kyber.Point {
+	return p.Add(a, p.c.Point().Neg(b))
+}
+
+func (p *curvePoint) Neg(a kyber.Point) kyber.Point {
+	ca := p.c.own(a)
+	if ca == nil {
+		return p.fail()
+	}
+	if ca.infinity() {
+		return p.Null()
+	}
+	p.x = new(big.Int).Set(ca.x)
+	p.y = new(big.Int).Sub(p.c.p.P, ca.y)
+	return p
+}
+
+func (p *curvePoint) Mul(s kyber.Scalar, b kyber.Point) kyber.Point {
+	ms, ok := s.(*mod.Int)
+	if !ok || ms.M.Cmp(p.c.p.N) != 0 {
+		return p.fail()
+	}
+	k := ms.V.Bytes()
+	if b == nil {
+		p.x, p.y = p.c.ScalarBaseMult(k)
+		return p
+	}
+
+	cb := p.c.own(b)
+	if cb == nil {
+		return p.fail()
+	}
+	if cb.infinity() {
+		return p.Null()
+	}
+	p.x, p.y = p.c.ScalarMult(cb.x, cb.y, k)
+	return p
+}
+
+func (p *curvePoint) Set(P kyber.Point) kyber.Point {
+	cP := p.c.own(P)
+	if cP == nil {
+		return p.fail()
+	}
+	p.x, p.y = cP.x, cP.y
+	return p
+}
+
+func (p *curvePoint) Clone() kyber.Point {
+	return &curvePoint{x: p.x, y: p.y, c: p.c, invalid: p.invalid}
+}
+
+func (p *curvePoint) MarshalSize() int {
+	return p.c.PointLen()
+}
+
+// Uncompressed ANSI X9.62 encoding, the point at infinity is all zeros.
+func (p *curvePoint) MarshalBinary() ([]byte, error) {
+	if p.invalid {
+		return nil, errors.New("invalid elliptic curve point")
+	}
+	if p.infinity() {
+		return make([]byte, p.c.PointLen()), nil
+	}
+	return elliptic.Marshal(p.c, p.x, p.y), nil
+}
+
+func (p *curvePoint) UnmarshalBinary(buf []byte) error {
+	if len(buf) != p.c.PointLen() {
+		return errors.New("invalid elliptic curve point length")
+	}
+
+	var c byte
+	for _, b := range buf {
+		c |= b
+	}
+	if c == 0 {
+		p.Null()
+		return nil
+	}
+
+	x, y := elliptic.Unmarshal(p.c, buf)
+	if x == nil {
+		return errors.New("invalid elliptic curve point")
+	}
+	p.x, p.y, p.invalid = x, y, false
+	return nil
+}
+
+func (p *curvePoint) MarshalTo(w io.Writer) (int, error) {
+	buf, err := p.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	return w.Write(buf)
+}
+
+// Decode a point from r or pick a random one if r is a stream.
+func (p *curvePoint) UnmarshalFrom(r io.Reader) (int, error) {
+	if stream, ok := r.(cipher.Stream); ok {
+		p.Pick(stream)
+		return -1, nil
+	}
+	buf := make([]byte, p.MarshalSize())
+	n, err := io.ReadFull(r, buf)
+	if err != nil {
+		return n, err
+	}
+	return n, p.UnmarshalBinary(buf)
+}
+
+// Abstract group on top of one of Go's native NIST curves. crypto/ecdh has no
+// point addition, so the arithmetic is that of the deprecated crypto/elliptic
+// functions, which the tests hold against crypto/ecdh and the NIST vectors.
+type curve struct {
+	elliptic.Curve
+	p    *elliptic.CurveParams
+	name string
+}
+
+func (c *curve) String() string {
+	return c.name
+}
+
+func (c *curve) ScalarLen() int { return (c.p.N.BitLen() + 7) / 8 }
+
+func (c *curve) Scalar() kyber.Scalar {
+	return mod.NewInt64(0, c.p.N)
+}
+
+func (c *curve) coordLen() int {
+	return (c.p.BitSize + 7) / 8
+}
+
+func (c *curve) PointLen() int {
+	return 1 + 2*c.coordLen()
+}
+
+func (c *curve) Point() kyber.Point {
+	p := new(curvePoint)
+	p.c = c
+	return p.Null()
+}
+
+type curveSuite struct {
+	curve
+	hash func() hash.Hash
+}
+
+func (s *curveSuite) Hash() hash.Hash {
+	return s.hash()
+}
+
+func (s *curveSuite) XOF(seed []byte) kyber.XOF {
+	return blake2xb.New(seed)
+}
+
+func (s *curveSuite) RandomStream() cipher.Stream {
+	return random.New()
+}
+
+func (s *curveSuite) Read(r io.Reader, objs ...interface{}) error {
+	return fixbuf.Read(r, s, objs...)
+}
+
+func (s *curveSuite) Write(w io.Writer, objs ...interface{}) error {
+	return fixbuf.Write(w, objs...)
+}
+
+func (s *curveSuite) New(t reflect.Type) interface{} {
+	return newElement(s, t)
+}
+
+func newCurveSuite(c elliptic.Curve, name string, h func() hash.Hash) Suite {
+	suite := new(curveSuite)
+	suite.Curve = c
+	suite.p = c.Params()
+	suite.name = name
+	suite.hash = h
+	return suite
+}
+
+// Ciphersuite based on BLAKE2Xb, SHA-384 and the NIST P-384 curve.
+func NewP384() Suite {
+	return newCurveSuite(elliptic.P384(), "P384", sha512.New384)
+}
+
+// Ciphersuite based on BLAKE2Xb, SHA-512 and the NIST P-521 curve.
+func NewP521() Suite {
+	return newCurveSuite(elliptic.P521(), "P521", sha512.New)
+}
@@ -0,0 +1,153 @@
+package suites
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// Multiples k*G of the NIST test vectors, x and y in hex.
+var curveVectors = map[string][]struct{ k, x, y string }{
+	"P256": {
+		{"2", "7CF27B188D034F7E8A52380304B51AC3C08969E277F21B35A60B48FC47669978",
+			"07775510DB8ED040293D9AC69F7430DBBA7DADE63CE982299E04B79D227873D1"},
+		{"3", "5ECBE4D1A6330A44C8F7EF951D4BF165E6C6B721EFADA985FB41661BC6E7FD6C",
+			"8734640C4998FF7E374B06CE1A64A2ECD82AB036384FB83D9A79B127A27D5032"},
+		{"112233445566778899", "339150844EC15234807FE862A86BE77977DBFB3AE3D96F4C22795513AEAAB82F",
+			"B1C14DDFDC8EC1B2583F51E85A5EB3A155840F2034730E9B5ADA38B674336A21"},
+	},
+	"P384": {
+		{"2", "08D999057BA3D2D969260045C55B97F089025959A6F434D651D207D19FB96E9E4FE0E86EBE0E64F85B96A9C75295DF61",
+			"8E80F1FA5B1B3CEDB7BFE8DFFD6DBA74B275D875BC6CC43E904E505F256AB4255FFD43E94D39E22D61501E700A940E80"},
+		{"3", "077A41D4606FFA1464793C7E5FDC7D98CB9D3910202DCD06BEA4F240D3566DA6B408BBAE5026580D02D7E5C70500C831",
+			"C995F7CA0B0C42837D0BBE9602A9FC998520B41C85115AA5F7684C0EDC111EACC24ABD6BE4B5D298B65F28600A2F1DF1"},
+		{"112233445566778899", "A499EFE48839BC3ABCD1C5CEDBDD51904F9514DB44F4686DB918983B0C9DC3AEE05A88B72433E9515F91A329F5F4FA60",
+			"3B7CA28EF31F809C2F1BA24AAED847D0F8B406A4B8968542DE139DB5828CA410E615D1182E25B91B1131E230B727D36A"},
+	},
+	"P521": {
+		{"2", "00433C219024277E7E682FCB288148C282747403279B1CCC06352C6E5505D769BE97B3B204DA6EF55507AA104A3A35C5AF41CF2FA364D60FD967F43E3933BA6D783D",
+			"00F4BB8CC7F86DB26700A7F3ECEEEED3F0B5C6B5107C4DA97740AB21A29906C42DBBB3E377DE9F251F6B93937FA99A3248F4EAFCBE95EDC0F4F71BE356D661F41B02"},
+		{"3", "01A73D352443DE29195DD91D6A64B5959479B52A6E5B123D9AB9E5AD7A112D7A8DD1AD3F164A3A4832051DA6BD16B59FE21BAEB490862C32EA05A5919D2EDE37AD7D",
+			"013E9B03B97DFA62DDD9979F86C6CAB814F2F1557FA82A9D0317D2F8AB1FA355CEEC2E2DD4CF8DC575B02D5ACED1DEC3C70CF105C9BC93A590425F588CA1EE86C0E5"},
+		{"112233445566778899", "01650048FBD63E8C30B305BF36BD7643B91448EF2206E8A0CA84A140789A99B0423A0A2533EA079CA7E049843E69E5FA2C25A163819110CEC1A30ACBBB3A422A40D8",
+			"010C9C64A0E0DB6052DBC5646687D06DECE5E9E0703153EFE9CB816FE025E85354D3C5F869D6DB3F4C0C01B5F97919A5E72CEEBE03042E5AA99112691CFFC2724828"},
+	},
+}
+
+func TestCurveVectors(t *testing.T) {
+	for name, vectors := range curveVectors {
+		suite, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range vectors {
+			k, _ := new(big.Int).SetString(v.k, 10)
+			s := suite.Scalar().SetBytes(k.Bytes())
+			want, _ := hex.DecodeString("04" + v.x + v.y)
+
+			// Multiples of the base, of G as an ordinary point and as sums
+			// of G and (k-1)G.
+			G := suite.Point().Base()
+			k1 := suite.Scalar().Sub(s, suite.Scalar().One())
+			for how, P := range map[string]kyber.Point{
+				"base":   suite.Point().Mul(s, nil),
+				"point":  suite.Point().Mul(s, G),
+				"sum":    suite.Point().Add(G, suite.Point().Mul(k1, G)),
+				"negsum": suite.Point().Sub(G, suite.Point().Mul(suite.Scalar().Neg(k1), G)),
+			} {
+				buf, err := P.MarshalBinary()
+				if err != nil || !bytes.Equal(buf, want) {
+					t.Fatalf("%s %s multiple %s: %x", name, how, v.k, buf)
+				}
+			}
+		}
+	}
+}
+
+// Multiplication agrees with the ECDH of the standard library.
+func TestCurveECDH(t *testing.T) {
+	for name, curve := range map[string]ecdh.Curve{
+		"P256": ecdh.P256(), "P384": ecdh.P384(), "P521": ecdh.P521(),
+	} {
+		suite, _ := Lookup(name)
+		stream := Seeded(suite, []byte(t.Name())).RandomStream()
+		a := suite.Scalar().Pick(stream)
+		b := suite.Scalar().Pick(stream)
+
+		private := func(s kyber.Scalar) *ecdh.PrivateKey {
+			buf, _ := s.MarshalBinary()
+			key, err := curve.NewPrivateKey(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return key
+		}
+		A := suite.Point().Mul(a, nil)
+		buf, _ := A.MarshalBinary()
+		if !bytes.Equal(buf, private(a).PublicKey().Bytes()) {
+			t.Fatalf("%s public key differs", name)
+		}
+		shared, err := private(b).ECDH(private(a).PublicKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, _ = suite.Point().Mul(b, A).MarshalBinary()
+		if !bytes.Equal(buf[1:1+len(shared)], shared) {
+			t.Fatalf("%s shared secret differs", name)
+		}
+	}
+}
+
+// Arithmetic with points of other groups yields invalid points instead of
+// panicking, while points of another instance of the same suite mix freely.
+func TestCurveForeign(t *testing.T) {
+	p384 := NewP384()
+	G := p384.Point().Base()
+	if !G.Equal(NewP384().Point().Base()) {
+		t.Fatal("base points of two instances differ")
+	}
+	sum := p384.Point().Add(G, NewP384().Point().Base())
+	if !valid(sum) || !sum.Equal(p384.Point().Mul(p384.Scalar().SetInt64(2), G)) {
+		t.Fatal("sum with a point of another instance")
+	}
+
+	p256, _ := Lookup("P256")
+	for name, foreign := range map[string]kyber.Point{
+		"P521": NewP521().Point().Base(),
+		"P256": p256.Point().Base(),
+	} {
+		if G.Equal(foreign) {
+			t.Fatalf("equal to a %s point", name)
+		}
+		for op, P := range map[string]kyber.Point{
+			"add":  p384.Point().Add(G, foreign),
+			"sub":  p384.Point().Sub(foreign, G),
+			"neg":  p384.Point().Neg(foreign),
+			"set":  p384.Point().Set(foreign),
+			"mul":  p384.Point().Mul(p384.Scalar().One(), foreign),
+			"then": p384.Point().Add(p384.Point().Neg(foreign), G),
+		} {
+			if valid(P) || P.Equal(P) || P.Equal(G) {
+				t.Fatalf("%s with a %s point valid", op, name)
+			}
+			if _, err := P.MarshalBinary(); err == nil {
+				t.Fatalf("%s with a %s point encoded", op, name)
+			}
+		}
+	}
+	P := p384.Point().Mul(p256.Scalar().One(), G)
+	if valid(P) {
+		t.Fatal("multiplication by a P256 scalar valid")
+	}
+	if !valid(P.Base()) {
+		t.Fatal("point invalid after reset")
+	}
+}
+
+func valid(P kyber.Point) bool {
+	return P.(*curvePoint).Valid()
+}
@@ -0,0 +1,28 @@
+package suites
+
+import (
+	"crypto/cipher"
+
+	"go.dedis.ch/kyber/v3"
+)
+
+// Suite whose randomness is drawn from a single XOF keyed with a seed.
+type seededSuite struct {
+	Suite
+	stream kyber.XOF
+}
+
+func (s *seededSuite) RandomStream() cipher.Stream {
+	return s.stream
+}
+
+// Return a view of suite whose RandomStream yields the same deterministic
+// stream on every call, so that key generation, encryption, permutations and
+// the private randomness of hash provers are all derived from seed. Every
+// call continues where the previous consumer stopped, hence the outcome
+// depends on the order of calls and the suite must not be shared between
+// goroutines. Only meant for test vectors and reproducible benchmarks: anyone
+// knowing the seed learns every secret.
+func Seeded(suite Suite, seed []byte) Suite {
+	return &seededSuite{suite, suite.XOF(append([]byte("evo seed "), seed...))}
+}
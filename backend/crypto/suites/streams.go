@@ -0,0 +1,22 @@
+package suites
+
+import (
+	"crypto/cipher"
+
+	"go.dedis.ch/kyber/v3/util/random"
+	"go.dedis.ch/kyber/v3/xof/blake2xb"
+)
+
+// Independent random streams for n concurrent workers. Every stream is a
+// BLAKE2Xb XOF keyed with 32 bytes drawn from stream, all keys are drawn
+// before Split returns. No worker touches stream itself, and a seeded stream
+// yields the same worker streams regardless of how the workers are
+// scheduled.
+func Split(stream cipher.Stream, n int) []cipher.Stream {
+	streams := make([]cipher.Stream, n)
+	for i := range streams {
+		key := random.Bits(256, false, stream)
+		streams[i] = blake2xb.New(append([]byte("evo worker "), key...))
+	}
+	return streams
+}
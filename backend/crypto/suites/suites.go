@@ -0,0 +1,106 @@
+/*
+Package suites is a registry of the ciphersuites that ballots can be encrypted
+and shuffled in. Suites are looked up by the name returned by their String
+method, which is also the identifier recorded in key files and transcripts.
+*/
+package suites
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/edwards25519"
+	"go.dedis.ch/kyber/v3/group/nist"
+)
+
+// Group together with the hash, XOF, encoding and randomness sources needed
+// to run and serialize proofs in it.
+type Suite interface {
+	kyber.Group
+	kyber.Encoding
+	kyber.HashFactory
+	kyber.XOFFactory
+	kyber.Random
+}
+
+// Name of the suite used if none is selected.
+const Default = "P256"
+
+var (
+	mutex     sync.Mutex
+	factories = map[string]func() Suite{}
+	instances = map[string]Suite{}
+)
+
+func init() {
+	Register("P256", func() Suite { return nist.NewBlakeSHA256P256() })
+	Register("P384", NewP384)
+	Register("P521", NewP521)
+	Register("Ed25519", func() Suite { return edwards25519.NewBlakeSHA256Ed25519() })
+	Register("Residue512", func() Suite { return nist.NewBlakeSHA256QR512() })
+	Register("Residue2048", NewQR2048)
+}
+
+// Make a suite available under the given name. The name has to match the
+// String of the suites returned by the factory.
+func Register(name string, factory func() Suite) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	factories[name] = factory
+	delete(instances, name)
+}
+
+// Return the suite registered under name. Suites are stateless and created
+// only once.
+func Lookup(name string) (Suite, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if suite, ok := instances[name]; ok {
+		return suite, nil
+	}
+
+	factory, ok := factories[name]
+	if !ok {
+		return nil, errors.New("unknown suite " + name)
+	}
+	suite := factory()
+	instances[name] = suite
+
+	return suite, nil
+}
+
+// Names of all registered suites in lexical order.
+func Names() []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+var (
+	scalarType = reflect.TypeOf((*kyber.Scalar)(nil)).Elem()
+	pointType  = reflect.TypeOf((*kyber.Point)(nil)).Elem()
+)
+
+// Create the scalar or point of group standing in for a nil interface of
+// type t while decoding.
+func newElement(group kyber.Group, t reflect.Type) interface{} {
+	switch t {
+	case scalarType:
+		return group.Scalar()
+	case pointType:
+		return group.Point()
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package suites_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/mixnet"
+	"github.com/qantik/evo/backend/crypto/suites"
+	"github.com/qantik/evo/backend/crypto/transcript"
+)
+
+func TestLookup(t *testing.T) {
+	if _, err := suites.Lookup(suites.Default); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := suites.Lookup("P255"); err == nil {
+		t.Fatal("unknown suite found")
+	}
+	for _, name := range suites.Names() {
+		suite, err := suites.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if suite.String() != name {
+			t.Fatalf("suite %s registered as %s", suite, name)
+		}
+	}
+}
+
+func TestSeeded(t *testing.T) {
+	suite, _ := suites.Lookup(suites.Default)
+	key := func(seed string) kyber.Point {
+		s := suites.Seeded(suite, []byte(seed))
+		return elgamal.GenerateKey(s, s.RandomStream()).Public
+	}
+	if !key("a").Equal(key("a")) {
+		t.Fatal("same seed gave different keys")
+	}
+	if key("a").Equal(key("b")) {
+		t.Fatal("different seeds gave the same key")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	suite, _ := suites.Lookup(suites.Default)
+	draw := func(seed string) [][]byte {
+		streams := suites.Split(suites.Seeded(suite, []byte(seed)).RandomStream(), 3)
+		out := make([][]byte, len(streams))
+		for i, stream := range streams {
+			out[i] = make([]byte, 16)
+			stream.XORKeyStream(out[i], out[i])
+		}
+		return out
+	}
+
+	a, b := draw("a"), draw("a")
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			t.Fatal("same seed gave different worker streams")
+		}
+		for j := 0; j < i; j++ {
+			if bytes.Equal(a[i], a[j]) {
+				t.Fatalf("workers %d and %d share a stream", j, i)
+			}
+		}
+	}
+	if bytes.Equal(a[0], draw("b")[0]) {
+		t.Fatal("different seeds gave the same worker stream")
+	}
+}
+
+// Shuffle a few ballots in every registered suite and check that the
+// transcript survives an encoding round-trip.
+func TestShuffle(t *testing.T) {
+	for _, name := range suites.Names() {
+		t.Run(name, func(t *testing.T) {
+			suite, _ := suites.Lookup(name)
+			stream := suite.RandomStream()
+			key := elgamal.GenerateKey(suite, stream)
+
+			X := make([]kyber.Point, 3)
+			Y := make([]kyber.Point, 3)
+			for i := range X {
+				X[i], Y[i], _ = elgamal.Encrypt(suite, key.Public, []byte(fmt.Sprintf("vote#%d", i)), stream)
+				m, err := elgamal.Decrypt(suite, key.Secret, X[i], Y[i])
+				if err != nil || string(m) != fmt.Sprintf("vote#%d", i) {
+					t.Fatalf("vote#%d decrypted to %q: %v", i, m, err)
+				}
+			}
+
+			cascade, err := mixnet.Run(suite, suite.Point().Base(), key.Public, X, Y, 1, stream)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sp := cascade.Transcripts()[0]
+			data, err := sp.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := new(transcript.ShuffleProof)
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Suite.String() != name {
+				t.Fatalf("transcript recorded suite %s", decoded.Suite)
+			}
+			if err := decoded.Verify(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,683 @@
+/*
+Package transcript defines serializable shuffle proof transcripts, so that a
+shuffle proven by one process can be verified by an independent auditor later.
+*/
+package transcript
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/compat"
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/neff"
+	"github.com/qantik/evo/backend/crypto/sato"
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+// Shuffle proof protocols.
+const (
+	Neff       = "neff"
+	SakoKilian = "sako-kilian"
+)
+
+// Protocol names fed into the Fiat-Shamir hash of version 1 and 2 proofs.
+var hashNames = map[string]string{
+	Neff:       "PS",
+	SakoKilian: "SK",
+}
+
+// Magic prefix of the binary encoding, followed by a version byte.
+var magic = []byte("EVO")
+
+// Transcript versions. Version 1 proofs were made with the Fiat-Shamir hash
+// of the former crypto.v0 suites. The hash of version 1 and 2 proofs is
+// seeded with the protocol name only, so that their challenges do not depend
+// on the statement. Version 3 seeds it with the complete statement, see
+// Domain, and version 4 adds the width of shuffles of several columns.
+const (
+	legacyVersion    = 1
+	labelVersion     = 2
+	statementVersion = 3
+	currentVersion   = 4
+)
+
+// The transcript is of a version whose Fiat-Shamir challenges do not depend
+// on the statement. Such a proof can be adapted to a statement of the
+// prover's choice, it is only accepted by VerifyLegacy.
+var ErrLegacy = errors.New("shuffle proof transcript predates statement binding")
+
+// Complete statement and proof of a single shuffle of the ElGamal pairs
+// (X, Y) into (Xbar, Ybar) under generator G and public key H. Rounds is
+// only used by Sako-Kilian proofs. Session identifies the election and Hop
+// the position of the shuffle in its mix cascade, both are part of the
+// statement from version 3 on. A shuffle of rows of Width pairs holds the
+// columns one after the other in the vectors, see Columns, a Width of zero
+// denotes a single column. Version is the transcript version, zero for the
+// current one.
+type ShuffleProof struct {
+	Suite      suites.Suite
+	Protocol   string
+	Rounds     int
+	Session    []byte
+	Hop        int
+	Width      int
+	G, H       kyber.Point
+	X, Y       []kyber.Point
+	Xbar, Ybar []kyber.Point
+	Proof      []byte
+	Version    int
+}
+
+func (sp *ShuffleProof) version() byte {
+	if sp.Version == 0 {
+		return currentVersion
+	}
+	return byte(sp.Version)
+}
+
+func (sp *ShuffleProof) width() int {
+	if sp.Width == 0 {
+		return 1
+	}
+	return sp.Width
+}
+
+// Number of rows, that is of ciphertexts per column.
+func (sp *ShuffleProof) Rows() int {
+	return len(sp.X) / sp.width()
+}
+
+// Split the statement vectors into their columns.
+func (sp *ShuffleProof) Columns() (X, Y, Xbar, Ybar [][]kyber.Point, err error) {
+	V := make([][][]kyber.Point, 4)
+	for i, v := range [][]kyber.Point{sp.X, sp.Y, sp.Xbar, sp.Ybar} {
+		if V[i], err = elgamal.Columns(v, sp.width()); err != nil {
+			return
+		}
+	}
+	return V[0], V[1], V[2], V[3], nil
+}
+
+// Set the statement vectors and the width from the columns of a wide
+// shuffle.
+func (sp *ShuffleProof) SetColumns(X, Y, Xbar, Ybar [][]kyber.Point) {
+	sp.Width = len(X)
+	sp.X, sp.Y = elgamal.Flatten(X), elgamal.Flatten(Y)
+	sp.Xbar, sp.Ybar = elgamal.Flatten(Xbar), elgamal.Flatten(Ybar)
+}
+
+// Name the Fiat-Shamir hash of the proof is seeded with. From version 3 on
+// this is the binary encoding of the statement, that is of everything but the
+// proof, which separates the protocols, sessions and hops.
+func (sp *ShuffleProof) Domain() (string, error) {
+	if sp.Legacy() {
+		return hashNames[sp.Protocol], nil
+	}
+
+	var buf bytes.Buffer
+	if err := sp.writeStatement(&buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Run the prover of the shuffle described by sp and store the proof.
+func (sp *ShuffleProof) Prove(prover proof.Prover) error {
+	if sp.version() != currentVersion {
+		return errors.New("only current transcripts can be proven")
+	}
+	name, err := sp.Domain()
+	if err != nil {
+		return err
+	}
+
+	sp.Proof, err = proof.HashProve(sp.Suite, name, prover)
+	return err
+}
+
+// Whether the Fiat-Shamir challenges of the proof do not depend on the
+// statement.
+func (sp *ShuffleProof) Legacy() bool {
+	return sp.version() < statementVersion
+}
+
+// Rerun the verifier of the recorded protocol on the transcript. Transcripts
+// of versions 1 and 2 are rejected with ErrLegacy.
+func (sp *ShuffleProof) Verify() error {
+	if sp.Legacy() {
+		return ErrLegacy
+	}
+	return sp.verify()
+}
+
+// Rerun the verifier like Verify but also accept transcripts of versions 1
+// and 2. Their proofs only show that some shuffle was proven, not that it is
+// this one, and are meant for auditing archived elections.
+func (sp *ShuffleProof) VerifyLegacy() error {
+	return sp.verify()
+}
+
+func (sp *ShuffleProof) verify() error {
+	n := len(sp.X)
+	if sp.Rows() <= 1 || len(sp.Y) != n || len(sp.Xbar) != n || len(sp.Ybar) != n {
+		return errors.New("malformed ShuffleProof")
+	}
+	if err := checkRounds(sp.Protocol, sp.Rounds); err != nil {
+		return err
+	}
+	// The commitments of every Sako-Kilian round alone hold two points per
+	// pair, the verifier allocates them before reading the proof.
+	if sp.Protocol == SakoKilian &&
+		len(sp.Proof) < sp.Rounds*2*n*sp.Suite.PointLen() {
+		return errors.New("truncated ShuffleProof")
+	}
+	X, Y, Xbar, Ybar, err := sp.Columns()
+	if err != nil {
+		return err
+	}
+
+	var verifier proof.Verifier
+	switch sp.Protocol {
+	case Neff:
+		verifier, err = neff.VerifierWide(sp.Suite, sp.G, sp.H, X, Y, Xbar, Ybar, 1)
+	case SakoKilian:
+		verifier, err = sato.VerifierWide(sp.Suite, sp.G, sp.H, X, Y, Xbar, Ybar,
+			sp.Rounds, false)
+	default:
+		return errors.New("unknown shuffle protocol " + sp.Protocol)
+	}
+	if err != nil {
+		return err
+	}
+
+	suite, err := sp.hashSuite()
+	if err != nil {
+		return err
+	}
+	name, err := sp.Domain()
+	if err != nil {
+		return err
+	}
+
+	return proof.HashVerify(suite, name, verifier, sp.Proof)
+}
+
+// Check the number of rounds of a transcript before anything is allocated for
+// them. Only Sako-Kilian proofs have rounds.
+func checkRounds(protocol string, rounds int) error {
+	if protocol != SakoKilian {
+		if rounds != 0 {
+			return errors.New("rounds given for a " + protocol + " transcript")
+		}
+		return nil
+	}
+	if rounds <= 0 {
+		return sato.ErrNoRounds
+	}
+	if rounds > sato.MaxRounds {
+		return sato.ErrTooManyRounds
+	}
+	return nil
+}
+
+// Suite whose XOF the Fiat-Shamir hash of the proof was computed with.
+func (sp *ShuffleProof) hashSuite() (suites.Suite, error) {
+	if sp.version() == legacyVersion {
+		return compat.Legacy(sp.Suite)
+	}
+	return sp.Suite, nil
+}
+
+// Verify many transcripts and return the outcome of each of them. The Neff
+// proofs of the same suite and version are batch verified, so that a single
+// multi-exponentiation covers all of them. Transcripts of versions 1 and 2 are
+// rejected with ErrLegacy unless legacy is set, see VerifyLegacy.
+func VerifyAll(sps []*ShuffleProof, legacy bool) []error {
+	errs := make([]error, len(sps))
+
+	var keys []string
+	batches := make(map[string][]int)
+	for i, sp := range sps {
+		if sp.Legacy() && !legacy {
+			errs[i] = ErrLegacy
+			continue
+		}
+		if sp.Protocol != Neff {
+			errs[i] = sp.verify()
+			continue
+		}
+		key := fmt.Sprintf("%s/%d", sp.Suite.String(), sp.version())
+		if _, ok := batches[key]; !ok {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], i)
+	}
+
+	for _, key := range keys {
+		batch := batches[key]
+		suite, err := sps[batch[0]].hashSuite()
+		if err != nil {
+			for _, i := range batch {
+				errs[i] = err
+			}
+			continue
+		}
+
+		var instances []neff.Instance
+		var members []int
+		for _, i := range batch {
+			sp := sps[i]
+			name, err := sp.Domain()
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			instances = append(instances, neff.Instance{Name: name, G: sp.G, H: sp.H,
+				X: sp.X, Y: sp.Y, Xbar: sp.Xbar, Ybar: sp.Ybar, Proof: sp.Proof,
+				Width: sp.width()})
+			members = append(members, i)
+		}
+		err = neff.BatchVerify(suite, instances, suite.RandomStream())
+		if batchErr, ok := err.(*neff.BatchError); ok {
+			for j, i := range members {
+				errs[i] = batchErr.Errs[j]
+			}
+		}
+	}
+
+	return errs
+}
+
+// Binary encoding, all integers are big-endian:
+//
+//	magic     "EVO" followed by the version byte 1 to 4
+//	suite     uint8 length followed by the suite name
+//	protocol  uint8 length followed by the protocol name
+//	rounds    uint32, at most sato.MaxRounds and zero for Neff proofs
+//	session   uint8 length followed by the session identifier, version 3
+//	hop       uint32, version 3
+//	width     uint32 number of columns, version 4
+//	k         uint32 number of pairs, width times the number of rows
+//	G, H      points
+//	X, Y      k points each
+//	Xbar, Ybar k points each
+//	proof     uint32 length followed by the proof bytes
+//
+// Points use the fixed-length MarshalBinary encoding of the suite.
+func (sp *ShuffleProof) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := sp.writeStatement(&buf); err != nil {
+		return nil, err
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint32(len(sp.Proof)))
+	buf.Write(sp.Proof)
+
+	return buf.Bytes(), nil
+}
+
+// Binary encoding up to the proof.
+func (sp *ShuffleProof) writeStatement(buf *bytes.Buffer) error {
+	buf.Write(magic)
+	buf.WriteByte(sp.version())
+
+	name := sp.Suite.String()
+	if len(name) > 255 || len(sp.Protocol) > 255 || len(sp.Session) > 255 {
+		return errors.New("identifier too long")
+	}
+	buf.WriteByte(byte(len(name)))
+	buf.WriteString(name)
+	buf.WriteByte(byte(len(sp.Protocol)))
+	buf.WriteString(sp.Protocol)
+
+	binary.Write(buf, binary.BigEndian, uint32(sp.Rounds))
+	if sp.version() >= statementVersion {
+		buf.WriteByte(byte(len(sp.Session)))
+		buf.Write(sp.Session)
+		binary.Write(buf, binary.BigEndian, uint32(sp.Hop))
+	}
+	if sp.version() >= currentVersion {
+		binary.Write(buf, binary.BigEndian, uint32(sp.width()))
+	} else if sp.width() != 1 {
+		return errors.New("wide shuffles need transcript version 4")
+	}
+	binary.Write(buf, binary.BigEndian, uint32(len(sp.X)))
+
+	return sp.Suite.Write(buf, sp.G, sp.H, sp.X, sp.Y, sp.Xbar, sp.Ybar)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	l, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	s := make([]byte, l)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+func (sp *ShuffleProof) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, magic) || len(data) == len(magic) {
+		return errors.New("not a shuffle proof transcript")
+	}
+	version := data[len(magic)]
+	if version < legacyVersion || version > currentVersion {
+		return errors.New("unsupported shuffle proof transcript version")
+	}
+	r := bytes.NewReader(data[len(magic)+1:])
+
+	name, err := readString(r)
+	if err != nil {
+		return err
+	}
+	suite, err := suites.Lookup(name)
+	if err != nil {
+		return err
+	}
+	protocol, err := readString(r)
+	if err != nil {
+		return err
+	}
+
+	var rounds, hop, k uint32
+	width := uint32(1)
+	if err := binary.Read(r, binary.BigEndian, &rounds); err != nil {
+		return err
+	}
+	if err := checkRounds(protocol, int(rounds)); err != nil {
+		return err
+	}
+	var session string
+	if version >= statementVersion {
+		if session, err = readString(r); err != nil {
+			return err
+		}
+		if err := binary.Read(r, binary.BigEndian, &hop); err != nil {
+			return err
+		}
+	}
+	if version >= currentVersion {
+		if err := binary.Read(r, binary.BigEndian, &width); err != nil {
+			return err
+		}
+	}
+	if err := binary.Read(r, binary.BigEndian, &k); err != nil {
+		return err
+	}
+	if width == 0 || k%width != 0 {
+		return errors.New("malformed shuffle proof transcript")
+	}
+	if uint64(k)*4*uint64(suite.PointLen()) > uint64(r.Len()) {
+		return errors.New("truncated shuffle proof transcript")
+	}
+
+	sp.Suite = suite
+	sp.Protocol = protocol
+	sp.Rounds = int(rounds)
+	sp.Session = nil
+	if session != "" {
+		sp.Session = []byte(session)
+	}
+	sp.Hop = int(hop)
+	sp.Width = int(width)
+	sp.Version = int(version)
+	sp.X = make([]kyber.Point, k)
+	sp.Y = make([]kyber.Point, k)
+	sp.Xbar = make([]kyber.Point, k)
+	sp.Ybar = make([]kyber.Point, k)
+	sp.G = suite.Point()
+	sp.H = suite.Point()
+	if err := suite.Read(r, sp.G, sp.H, sp.X, sp.Y, sp.Xbar, sp.Ybar); err != nil {
+		return err
+	}
+
+	var l uint32
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return err
+	}
+	if uint64(l) != uint64(r.Len()) {
+		return errors.New("malformed shuffle proof transcript")
+	}
+	sp.Proof = make([]byte, l)
+	_, err = io.ReadFull(r, sp.Proof)
+
+	return err
+}
+
+// JSON encoding, points are hex strings of their MarshalBinary output and
+// the proof and session are base64 encoded. A missing version denotes
+// version 1, a missing width a single column.
+type jsonProof struct {
+	Version  int      `json:"version,omitempty"`
+	Suite    string   `json:"suite"`
+	Protocol string   `json:"protocol"`
+	Rounds   int      `json:"rounds,omitempty"`
+	Session  []byte   `json:"session,omitempty"`
+	Hop      int      `json:"hop,omitempty"`
+	Width    int      `json:"width,omitempty"`
+	G        string   `json:"g"`
+	H        string   `json:"h"`
+	X        []string `json:"x"`
+	Y        []string `json:"y"`
+	Xbar     []string `json:"xbar"`
+	Ybar     []string `json:"ybar"`
+	Proof    []byte   `json:"proof"`
+}
+
+func encodePoints(P ...kyber.Point) ([]string, error) {
+	s := make([]string, len(P))
+	for i := range P {
+		buf, err := P[i].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		s[i] = hex.EncodeToString(buf)
+	}
+	return s, nil
+}
+
+func decodePoints(group kyber.Group, s []string) ([]kyber.Point, error) {
+	P := make([]kyber.Point, len(s))
+	for i := range s {
+		buf, err := hex.DecodeString(s[i])
+		if err != nil {
+			return nil, err
+		}
+		P[i] = group.Point()
+		if err := P[i].UnmarshalBinary(buf); err != nil {
+			return nil, err
+		}
+	}
+	return P, nil
+}
+
+func (sp *ShuffleProof) MarshalJSON() ([]byte, error) {
+	jp := jsonProof{
+		Version:  int(sp.version()),
+		Suite:    sp.Suite.String(),
+		Protocol: sp.Protocol,
+		Rounds:   sp.Rounds,
+		Proof:    sp.Proof,
+	}
+	if jp.Version >= statementVersion {
+		jp.Session, jp.Hop = sp.Session, sp.Hop
+	}
+	if jp.Version >= currentVersion {
+		jp.Width = sp.width()
+	} else if sp.width() != 1 {
+		return nil, errors.New("wide shuffles need transcript version 4")
+	}
+
+	GH, err := encodePoints(sp.G, sp.H)
+	if err != nil {
+		return nil, err
+	}
+	jp.G, jp.H = GH[0], GH[1]
+
+	for _, v := range []struct {
+		dst *[]string
+		src []kyber.Point
+	}{{&jp.X, sp.X}, {&jp.Y, sp.Y}, {&jp.Xbar, sp.Xbar}, {&jp.Ybar, sp.Ybar}} {
+		if *v.dst, err = encodePoints(v.src...); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(jp)
+}
+
+func (sp *ShuffleProof) UnmarshalJSON(data []byte) error {
+	var jp jsonProof
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	if jp.Version > currentVersion {
+		return errors.New("unsupported shuffle proof transcript version")
+	}
+	if err := checkRounds(jp.Protocol, jp.Rounds); err != nil {
+		return err
+	}
+
+	suite, err := suites.Lookup(jp.Suite)
+	if err != nil {
+		return err
+	}
+
+	GH, err := decodePoints(suite, []string{jp.G, jp.H})
+	if err != nil {
+		return err
+	}
+
+	sp.Suite = suite
+	sp.Protocol = jp.Protocol
+	sp.Rounds = jp.Rounds
+	sp.G, sp.H = GH[0], GH[1]
+	sp.Proof = jp.Proof
+	sp.Version = jp.Version
+	if sp.Version < legacyVersion {
+		sp.Version = legacyVersion
+	}
+	sp.Session, sp.Hop, sp.Width = nil, 0, 1
+	if sp.Version >= statementVersion {
+		sp.Session, sp.Hop = jp.Session, jp.Hop
+	}
+	if sp.Version >= currentVersion && jp.Width != 0 {
+		sp.Width = jp.Width
+	}
+	if sp.Width < 1 {
+		return errors.New("malformed shuffle proof transcript")
+	}
+
+	for _, v := range []struct {
+		dst *[]kyber.Point
+		src []string
+	}{{&sp.X, jp.X}, {&sp.Y, jp.Y}, {&sp.Xbar, jp.Xbar}, {&sp.Ybar, jp.Ybar}} {
+		if *v.dst, err = decodePoints(suite, v.src); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read the transcripts stored in a file. The file contains either a single
+// binary transcript, a single JSON transcript or a JSON array of transcripts
+// of consecutive mix hops.
+func ReadFile(path string) ([]*ShuffleProof, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, magic) {
+		sp := new(ShuffleProof)
+		if err := sp.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return []*ShuffleProof{sp}, nil
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var sps []*ShuffleProof
+		if err := json.Unmarshal(data, &sps); err != nil {
+			return nil, err
+		}
+		return sps, nil
+	}
+
+	sp := new(ShuffleProof)
+	if err := json.Unmarshal(data, sp); err != nil {
+		return nil, err
+	}
+	return []*ShuffleProof{sp}, nil
+}
+
+// Write the transcripts as a JSON array to a file.
+func WriteFile(path string, sps []*ShuffleProof) error {
+	data, err := json.MarshalIndent(sps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Check that next is the hop following prev in the same mix cascade: both
+// shuffle under the same suite, protocol, generator and key and the input of
+// next is the output of prev. Transcripts bound to their statement also have
+// to belong to the same session and next has to be the following hop.
+func Link(prev, next *ShuffleProof) error {
+	switch {
+	case prev.Suite.String() != next.Suite.String():
+		return errors.New("suite differs from the previous hop")
+	case prev.Protocol != next.Protocol:
+		return errors.New("protocol differs from the previous hop")
+	case !prev.G.Equal(next.G) || !prev.H.Equal(next.H):
+		return errors.New("election key differs from the previous hop")
+	case prev.version() != next.version():
+		return errors.New("version differs from the previous hop")
+	case prev.width() != next.width():
+		return errors.New("width differs from the previous hop")
+	}
+
+	if !next.Legacy() {
+		if !bytes.Equal(prev.Session, next.Session) {
+			return errors.New("session differs from the previous hop")
+		}
+		if next.Hop != prev.Hop+1 {
+			return fmt.Errorf("hop %d follows hop %d", next.Hop, prev.Hop)
+		}
+	}
+
+	if len(prev.Xbar) != len(next.X) || len(prev.Ybar) != len(next.Y) {
+		return errors.New("input is not the output of the previous hop")
+	}
+	for j := range next.X {
+		if !prev.Xbar[j].Equal(next.X[j]) || !prev.Ybar[j].Equal(next.Y[j]) {
+			return errors.New("input is not the output of the previous hop")
+		}
+	}
+	return nil
+}
+
+// Check that every transcript is linked to its predecessor, see Link.
+func Chained(sps []*ShuffleProof) bool {
+	for i := 1; i < len(sps); i++ {
+		if Link(sps[i-1], sps[i]) != nil {
+			return false
+		}
+	}
+	return true
+}
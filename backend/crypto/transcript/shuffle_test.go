@@ -0,0 +1,349 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/group/nist"
+	"go.dedis.ch/kyber/v3/proof"
+
+	"github.com/qantik/evo/backend/crypto/elgamal"
+	"github.com/qantik/evo/backend/crypto/neff"
+	"github.com/qantik/evo/backend/crypto/sato"
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+func shuffle(t *testing.T, protocol string) *ShuffleProof {
+	return prove(t, nist.NewBlakeSHA256P256(), protocol)
+}
+
+// Encrypt four ballots under a fresh key and shuffle them, drawing all
+// randomness from the suite.
+func prove(t *testing.T, suite suites.Suite, protocol string) *ShuffleProof {
+	return proveWide(t, suite, protocol, 1)
+}
+
+// Shuffle rows of width pairs and record the transcript.
+func proveWide(t *testing.T, suite suites.Suite, protocol string, width int) *ShuffleProof {
+	stream := suite.RandomStream()
+	key := elgamal.GenerateKey(suite, stream)
+
+	k := 4
+	sp := &ShuffleProof{
+		Suite:    suite,
+		Protocol: protocol,
+		Session:  []byte("evo test"),
+		Hop:      1,
+		G:        suite.Point().Base(),
+		H:        key.Public,
+	}
+	X := make([][]kyber.Point, width)
+	Y := make([][]kyber.Point, width)
+	for j := range X {
+		X[j] = make([]kyber.Point, k)
+		Y[j] = make([]kyber.Point, k)
+	}
+	for i := 0; i < k; i++ {
+		for j := range X {
+			X[j][i], Y[j][i], _ = elgamal.Encrypt(suite, key.Public,
+				[]byte(fmt.Sprint(i*width+j)), stream)
+		}
+	}
+
+	var Xbar, Ybar [][]kyber.Point
+	var prover proof.Prover
+	var err error
+	if protocol == Neff {
+		Xbar, Ybar, prover, err = neff.ShuffleWide(suite, sp.G, sp.H, X, Y, stream)
+	} else {
+		sp.Rounds = 16
+		Xbar, Ybar, prover, err = sato.ShuffleWide(suite, sp.G, sp.H, X, Y,
+			sp.Rounds, false, stream)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp.SetColumns(X, Y, Xbar, Ybar)
+
+	if err := sp.Prove(prover); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sp.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	return sp
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, protocol := range []string{Neff, SakoKilian} {
+		sp := shuffle(t, protocol)
+		buf, err := sp.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dec ShuffleProof
+		if err := dec.UnmarshalBinary(buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if !dec.Ybar[2].Equal(sp.Ybar[2]) || dec.Rounds != sp.Rounds ||
+			string(dec.Session) != string(sp.Session) || dec.Hop != sp.Hop {
+			t.Fatal("transcript changed in round trip")
+		}
+
+		if dec.UnmarshalBinary(buf[:len(buf)-1]) == nil {
+			t.Fatal("truncated transcript decoded")
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, protocol := range []string{Neff, SakoKilian} {
+		sp := shuffle(t, protocol)
+		buf, err := json.Marshal(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var dec ShuffleProof
+		if err := json.Unmarshal(buf, &dec); err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Verify(); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(dec.Session) != string(sp.Session) || dec.Hop != sp.Hop {
+			t.Fatal("session changed in round trip")
+		}
+
+		dec.Xbar[0], dec.Xbar[1] = dec.Xbar[1], dec.Xbar[0]
+		if dec.Verify() == nil {
+			t.Fatal("tampered transcript verified")
+		}
+	}
+}
+
+// The number of rounds is bounded before the verifier allocates the rounds,
+// and only Sako-Kilian transcripts have any.
+func TestRounds(t *testing.T) {
+	sp := shuffle(t, SakoKilian)
+	buf, err := sp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Offset of the rounds behind magic, version, suite and protocol.
+	off := len(magic) + 1 + 1 + len(sp.Suite.String()) + 1 + len(sp.Protocol)
+	if binary.BigEndian.Uint32(buf[off:]) != uint32(sp.Rounds) {
+		t.Fatal("rounds not found")
+	}
+	huge := append([]byte(nil), buf...)
+	binary.BigEndian.PutUint32(huge[off:], 0xffffffff)
+	var dec ShuffleProof
+	if err := dec.UnmarshalBinary(huge); err == nil {
+		t.Fatal("transcript with 2^32-1 rounds decoded")
+	}
+
+	jp, _ := json.Marshal(sp)
+	jp = bytes.Replace(jp, []byte(`"rounds":16`), []byte(`"rounds":4294967295`), 1)
+	if err := json.Unmarshal(jp, &dec); err != sato.ErrTooManyRounds {
+		t.Fatalf("JSON transcript with 2^32-1 rounds: %v", err)
+	}
+
+	// More rounds than the proof can hold are rejected before verifying.
+	more := *sp
+	more.Rounds = sato.MaxRounds
+	if err := more.Verify(); err == nil {
+		t.Fatal("proof verified with more rounds")
+	}
+
+	neffProof := shuffle(t, Neff)
+	neffProof.Rounds = 16
+	if neffProof.Verify() == nil {
+		t.Fatal("Neff transcript with rounds verified")
+	}
+	buf, err = neffProof.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.UnmarshalBinary(buf) == nil {
+		t.Fatal("Neff transcript with rounds decoded")
+	}
+}
+
+// Proofs only verify for the exact statement, session and hop they were made
+// for.
+func TestStatementBinding(t *testing.T) {
+	for _, protocol := range []string{Neff, SakoKilian} {
+		t.Run(protocol, func(t *testing.T) {
+			sp := shuffle(t, protocol)
+			for name, change := range map[string]func(sp *ShuffleProof){
+				"session": func(sp *ShuffleProof) { sp.Session = []byte("other") },
+				"hop":     func(sp *ShuffleProof) { sp.Hop++ },
+				"key":     func(sp *ShuffleProof) { sp.H = sp.Suite.Point().Add(sp.H, sp.G) },
+				"version": func(sp *ShuffleProof) { sp.Version = labelVersion },
+			} {
+				changed := *sp
+				change(&changed)
+				if changed.Verify() == nil {
+					t.Fatalf("proof verified with a different %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAll(t *testing.T) {
+	sps := []*ShuffleProof{shuffle(t, Neff), shuffle(t, SakoKilian), shuffle(t, Neff),
+		shuffle(t, Neff)}
+	sps[3].Xbar[0], sps[3].Xbar[1] = sps[3].Xbar[1], sps[3].Xbar[0]
+
+	for i, err := range VerifyAll(sps, false) {
+		if (err != nil) != (i == 3) {
+			t.Fatalf("transcript %d: %v", i, err)
+		}
+	}
+
+	// Transcripts downgraded to a version without statement binding.
+	sps[3].Xbar[0], sps[3].Xbar[1] = sps[3].Xbar[1], sps[3].Xbar[0]
+	sps[1].Version = labelVersion
+	sps[2].Version = labelVersion
+	for i, err := range VerifyAll(sps, false) {
+		want := error(nil)
+		if i == 1 || i == 2 {
+			want = ErrLegacy
+		}
+		if err != want {
+			t.Fatalf("transcript %d: %v", i, err)
+		}
+	}
+}
+
+// Shuffle (X, Y) as the given hop of a Neff cascade of the session.
+func hop(t *testing.T, suite suites.Suite, h kyber.Point, X, Y []kyber.Point,
+	session string, index int) *ShuffleProof {
+
+	sp := &ShuffleProof{
+		Suite:    suite,
+		Protocol: Neff,
+		Session:  []byte(session),
+		Hop:      index,
+		G:        suite.Point().Base(),
+		H:        h,
+		X:        X,
+		Y:        Y,
+	}
+	var prover proof.Prover
+	var err error
+	sp.Xbar, sp.Ybar, prover, err = neff.Shuffle(suite, sp.G, sp.H, X, Y, suite.RandomStream())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.Prove(prover); err != nil {
+		t.Fatal(err)
+	}
+	return sp
+}
+
+// Every hop of a chain continues the previous one of the same session.
+func TestLink(t *testing.T) {
+	first := shuffle(t, Neff)
+	suite := first.Suite
+	second := hop(t, suite, first.H, first.Xbar, first.Ybar, "evo test", 2)
+	if !Chained([]*ShuffleProof{first, second}) {
+		t.Fatal(Link(first, second))
+	}
+
+	// Valid proofs of another session, a repeated hop and a skipped hop
+	// continuing the output of the first hop.
+	other := suites.Seeded(suite, []byte("other"))
+	key := elgamal.GenerateKey(other, other.RandomStream())
+	for name, next := range map[string]*ShuffleProof{
+		"session":      hop(t, suite, first.H, first.Xbar, first.Ybar, "other election", 2),
+		"repeated hop": hop(t, suite, first.H, first.Xbar, first.Ybar, "evo test", 1),
+		"skipped hop":  hop(t, suite, first.H, first.Xbar, first.Ybar, "evo test", 3),
+		"key":          hop(t, suite, key.Public, first.Xbar, first.Ybar, "evo test", 2),
+		"input":        hop(t, suite, first.H, first.X, first.Y, "evo test", 2),
+	} {
+		if err := next.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if Link(first, next) == nil {
+			t.Fatalf("hop with a different %s linked", name)
+		}
+	}
+
+	// Two sessions spliced together, each hop valid on its own.
+	a := hop(t, suite, first.H, first.Xbar, first.Ybar, "a", 1)
+	b := hop(t, suite, first.H, a.Xbar, a.Ybar, "b", 2)
+	c := hop(t, suite, first.H, b.Xbar, b.Ybar, "a", 3)
+	if Chained([]*ShuffleProof{a, b, c}) {
+		t.Fatal("spliced sessions chained")
+	}
+}
+
+// Shuffles of rows of several pairs bind their width and survive both
+// encodings.
+func TestWide(t *testing.T) {
+	for _, protocol := range []string{Neff, SakoKilian} {
+		t.Run(protocol, func(t *testing.T) {
+			sp := proveWide(t, nist.NewBlakeSHA256P256(), protocol, 3)
+			if sp.Rows() != 4 || len(sp.X) != 12 {
+				t.Fatalf("%d rows of %d pairs", sp.Rows(), len(sp.X))
+			}
+
+			buf, err := json.Marshal(sp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dec ShuffleProof
+			if err := json.Unmarshal(buf, &dec); err != nil {
+				t.Fatal(err)
+			}
+			if err := dec.Verify(); err != nil || dec.Width != 3 {
+				t.Fatalf("width %d: %v", dec.Width, err)
+			}
+			if errs := VerifyAll([]*ShuffleProof{sp, &dec}, false); errs[0] != nil || errs[1] != nil {
+				t.Fatal(errs)
+			}
+
+			for name, change := range map[string]func(sp *ShuffleProof){
+				"single column": func(sp *ShuffleProof) { sp.Width = 1 },
+				"other width":   func(sp *ShuffleProof) { sp.Width = 2 },
+				"version 3":     func(sp *ShuffleProof) { sp.Version = statementVersion },
+			} {
+				changed := *sp
+				change(&changed)
+				if changed.Verify() == nil {
+					t.Fatalf("proof verified as %s", name)
+				}
+			}
+
+			old := *sp
+			old.Version = statementVersion
+			if _, err := old.MarshalBinary(); err == nil {
+				t.Fatal("wide shuffle encoded as version 3")
+			}
+		})
+	}
+
+	// A single column shuffle of the flattened output does not continue a
+	// wide hop.
+	sp := proveWide(t, nist.NewBlakeSHA256P256(), Neff, 3)
+	next := hop(t, sp.Suite, sp.H, sp.Xbar, sp.Ybar, "evo test", 2)
+	if err := next.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if Link(sp, next) == nil {
+		t.Fatal("hop of another width linked")
+	}
+}
@@ -0,0 +1,150 @@
+package transcript
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+)
+
+var update = flag.Bool("update", false, "rewrite the known-answer vectors")
+
+// Known-answer vectors: shuffles run by a seeded suite have to reproduce the
+// recorded transcripts byte for byte.
+func TestVectors(t *testing.T) {
+	for _, protocol := range []string{Neff, SakoKilian} {
+		for _, name := range []string{"P256", "Ed25519", "Residue512"} {
+			for _, width := range []int{1, 3} {
+				vector(t, protocol, name, width)
+			}
+		}
+	}
+}
+
+func vector(t *testing.T, protocol, name string, width int) {
+	vname := protocol + "-" + name
+	if width > 1 {
+		vname += fmt.Sprintf("-w%d", width)
+	}
+	file := filepath.Join("testdata", vname+".bin")
+	t.Run(vname, func(t *testing.T) {
+		suite, err := suites.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sp := proveWide(t, suites.Seeded(suite, []byte("evo vectors")), protocol, width)
+		buf, err := sp.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *update {
+			if err := ioutil.WriteFile(file, buf, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, want) {
+			t.Fatalf("transcript differs from %s", file)
+		}
+
+		// The vector itself has to verify without the seeded suite.
+		var dec ShuffleProof
+		if err := dec.UnmarshalBinary(want); err != nil {
+			t.Fatal(err)
+		}
+		if err := dec.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if dec.Width != width {
+			t.Fatalf("width %d", dec.Width)
+		}
+	})
+}
+
+// Version 2 vectors, recorded before the statement was bound into the
+// Fiat-Shamir hash, are rejected by default and only verify as legacy
+// transcripts.
+func TestVersion2(t *testing.T) {
+	for _, sp := range older(t, "v2", labelVersion) {
+		if err := sp.Verify(); err != ErrLegacy {
+			t.Fatalf("version 2 vector accepted by default: %v", err)
+		}
+		if err := sp.VerifyLegacy(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Version 3 vectors, recorded before transcripts carried a width, still
+// verify as single column shuffles.
+func TestVersion3(t *testing.T) {
+	for _, sp := range older(t, "v3", statementVersion) {
+		if err := sp.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if sp.Width != 1 {
+			t.Fatalf("version 3 vector of width %d", sp.Width)
+		}
+	}
+}
+
+// Decode the vectors of an older version, which have to survive a round
+// trip unchanged.
+func older(t *testing.T, dir string, version int) []*ShuffleProof {
+	files, err := filepath.Glob(filepath.Join("testdata", dir, "*.bin"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no version %d vectors", version)
+	}
+	var sps []*ShuffleProof
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sp := new(ShuffleProof)
+		if err := sp.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if sp.Version != version {
+			t.Fatalf("%s: version %d", file, sp.Version)
+		}
+		buf, err := sp.MarshalBinary()
+		if err != nil || !bytes.Equal(buf, data) {
+			t.Fatalf("%s changed in round trip", file)
+		}
+		sps = append(sps, sp)
+	}
+	return sps
+}
+
+// A version 2 proof does not depend on the session and hop of its statement,
+// so it is rejected whatever statement it is presented with.
+func TestVersion2Replay(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "v2", "neff-P256.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sp ShuffleProof
+	if err := sp.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	sp.Session, sp.Hop = []byte("another election"), 7
+	if err := sp.VerifyLegacy(); err != nil {
+		t.Fatalf("legacy proof no longer replays: %v", err)
+	}
+	if err := sp.Verify(); err != ErrLegacy {
+		t.Fatalf("replayed version 2 proof: %v", err)
+	}
+	if errs := VerifyAll([]*ShuffleProof{&sp}, false); errs[0] != ErrLegacy {
+		t.Fatalf("replayed version 2 proof in batch: %v", errs[0])
+	}
+}
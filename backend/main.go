@@ -1,7 +1,16 @@
 package main
 
-import "github.com/qantik/evo/backend/net"
+import (
+	"flag"
+
+	"github.com/qantik/evo/backend/crypto/suites"
+	"github.com/qantik/evo/backend/net"
+)
 
 func main() {
-	_ = net.Open("../frontend")
+	suite := flag.String("suite", suites.Default, "default suite of queries and the election key")
+	key := flag.String("key", "", "election key file, generated by evo-keygen")
+	flag.Parse()
+
+	_ = net.Open("../frontend", *suite, *key)
 }
@@ -1,19 +1,24 @@
 package net
 
 import (
+	"crypto/cipher"
 	"fmt"
+	"log"
 	"net/http"
-	"sync"
+	"os"
+	"runtime"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"gopkg.in/dedis/crypto.v0/abstract"
-	"gopkg.in/dedis/crypto.v0/nist"
-	"gopkg.in/dedis/crypto.v0/proof"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/proof"
+	"go.dedis.ch/kyber/v3/util/random"
 
 	"github.com/qantik/evo/backend/crypto/elgamal"
-	"github.com/qantik/evo/backend/crypto/neff"
+	"github.com/qantik/evo/backend/crypto/mixnet"
 	"github.com/qantik/evo/backend/crypto/sato"
+	"github.com/qantik/evo/backend/crypto/suites"
+	"github.com/qantik/evo/backend/crypto/transcript"
 )
 
 // Base backend structure comprising all necessary fields
@@ -23,58 +28,162 @@ type Server struct {
 	clients   map[*websocket.Conn]bool
 	broadcast chan query
 	upgrader  websocket.Upgrader
+	suite     string
+	keys      map[string]*elgamal.KeyPair
+	bases     map[string]*elgamal.Bases
 }
 
 type query struct {
-	Votes       []string `json: "votes"`
-	Algorithm   string   `json: "algorithm"`
-	Parallelize bool     `json: "parallelize"`
+	Votes       []string `json:"votes"`
+	Algorithm   string   `json:"algorithm"`
+	Parallelize bool     `json:"parallelize"`
+	Mixers      int      `json:"mixers"`
+	Suite       string   `json:"suite"`
+	Session     string   `json:"session"`
 }
 
+// Outcome of a query. Shuffle covers the re-encryption and permutation of
+// the pairs, Prove the proof generation including the embedded simple
+// k-shuffle of Neff's proof timed in Simple, Verify the proof verification
+// and Time all of them.
 type response struct {
-	Time string `json: "time"`
+	Algorithm string `json:"algorithm"`
+	Suite     string `json:"suite"`
+	Votes     int    `json:"votes"`
+	Width     int    `json:"width"`
+	Verified  bool   `json:"verified"`
+	Shuffle   string `json:"shuffle"`
+	Prove     string `json:"prove"`
+	Simple    string `json:"simple,omitempty"`
+	Verify    string `json:"verify"`
+	Time      string `json:"time"`
+	Error     string `json:"error,omitempty"`
 }
 
-// Create an ElGamal encryption pair for each data string object.
-func encrypt(suite abstract.Suite, data []string, stream abstract.Cipher) (
-	A, B []abstract.Point) {
+// Fill in the timings and the verification outcome of a response.
+func (res *response) finish(t mixnet.Timings, verify time.Duration, err error) {
+	res.Shuffle = t.Shuffle.String()
+	res.Prove = t.Prove.String()
+	if t.Simple > 0 {
+		res.Simple = t.Simple.String()
+	}
+	res.Verify = verify.String()
+	res.Time = (t.Shuffle + t.Prove + verify).String()
+	res.Verified = err == nil
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	log.Printf("%s %s k=%d width=%d shuffle=%s prove=%s simple=%s verify=%s verified=%t %s",
+		res.Algorithm, res.Suite, res.Votes, res.Width, t.Shuffle, t.Prove, t.Simple,
+		verify, res.Verified, res.Error)
+}
+
+// Encrypt every data string object as a row of ciphertexts under the
+// election key, returned column by column. All votes are length-padded to
+// the width of the longest one, so that the rows do not tell them apart.
+func encrypt(group kyber.Group, bases *elgamal.Bases, data []string,
+	stream cipher.Stream) (A, B [][]kyber.Point, err error) {
+
+	longest := 0
+	for _, vote := range data {
+		if len(vote) > longest {
+			longest = len(vote)
+		}
+	}
+	width, err := elgamal.Width(group, longest)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	k := len(data)
-	A = make([]abstract.Point, k)
-	B = make([]abstract.Point, k)
+	A = make([][]kyber.Point, width)
+	B = make([][]kyber.Point, width)
+	for j := range A {
+		A[j] = make([]kyber.Point, k)
+		B[j] = make([]kyber.Point, k)
+	}
 
 	for i := 0; i < k; i++ {
-		secret := suite.Scalar().Pick(stream)
-		public := suite.Point().Mul(nil, secret)
-
-		alpha, beta := elgamal.Encrypt(suite, public, []byte(data[i]))
-		A[i] = alpha
-		B[i] = beta
+		alpha, beta, err := bases.EncryptRow([]byte(data[i]), width, stream)
+		if err != nil {
+			return nil, nil, fmt.Errorf("vote %d: %v", i, err)
+		}
+		for j := range alpha {
+			A[j][i] = alpha[j]
+			B[j][i] = beta[j]
+		}
 	}
 
 	return
 }
 
-func verifyNeff(suite abstract.Suite, A, B []abstract.Point, stream abstract.Cipher) {
-	Ap, Bp, prover := neff.Shuffle(suite, nil, nil, A, B, stream)
-	stamp, _ := proof.HashProve(suite, "PS", stream, prover)
+// Run a cascade of Neff mix servers and verify all hops. If parallel is set
+// every hop is proven and verified on all cores.
+func verifyNeff(suite suites.Suite, bases *elgamal.Bases, A, B [][]kyber.Point,
+	session []byte, mixers int, parallel bool, stream cipher.Stream, res *response) {
 
-	verifier := neff.Verifier(suite, nil, nil, A, B, Ap, Bp)
-	_ = proof.HashVerify(suite, "PS", verifier, stamp)
+	if mixers < 1 {
+		mixers = 1
+	}
+
+	cascade := mixnet.NewWide(suite, bases.G, bases.H, A, B).SetBases(bases).SetSession(session)
+	if parallel {
+		cascade.SetWorkers(runtime.NumCPU())
+	}
+	for i := 0; i < mixers; i++ {
+		if err := cascade.Mix(stream); err != nil {
+			res.finish(cascade.Timings(), 0, err)
+			return
+		}
+	}
+
+	start := time.Now()
+	err := cascade.Verify()
+	res.finish(cascade.Timings(), time.Since(start), err)
 }
 
-func verifySato(wg *sync.WaitGroup, p bool, suite abstract.Suite, A, B []abstract.Point,
-	stream abstract.Cipher) {
+func verifySato(suite suites.Suite, h kyber.Point, A, B [][]kyber.Point,
+	session []byte, parallel bool, stream cipher.Stream, res *response) {
 
-	if p {
-		defer wg.Done()
+	var t mixnet.Timings
+	sp := &transcript.ShuffleProof{
+		Suite:    suite,
+		Protocol: transcript.SakoKilian,
+		Rounds:   sato.DefaultRounds,
+		Session:  session,
+		G:        suite.Point().Base(),
+		H:        h,
 	}
 
-	Ap, Bp, prover := sato.Shuffle(suite, nil, nil, A, B, stream)
-	stamp, _ := proof.HashProve(suite, "SK", stream, prover)
+	start := time.Now()
+	Abar, Bbar, prover, err := sato.ShuffleWide(suite, sp.G, h, A, B, sp.Rounds,
+		parallel, stream)
+	t.Shuffle = time.Since(start)
+	if err != nil {
+		res.finish(t, 0, err)
+		return
+	}
+	sp.SetColumns(A, B, Abar, Bbar)
 
-	verifier := sato.Verifier(suite, nil, nil, A, B, Ap, Bp)
-	_ = proof.HashVerify(suite, "SK", verifier, stamp)
+	start = time.Now()
+	err = sp.Prove(prover)
+	t.Prove = time.Since(start)
+	if err != nil {
+		res.finish(t, 0, err)
+		return
+	}
+
+	start = time.Now()
+	verifier, err := sato.VerifierWide(suite, sp.G, h, A, B, Abar, Bbar, sp.Rounds,
+		parallel)
+	if err == nil {
+		var name string
+		if name, err = sp.Domain(); err == nil {
+			err = proof.HashVerify(suite, name, verifier, sp.Proof)
+		}
+	}
+	res.finish(t, time.Since(start), err)
 }
 
 // Register incoming new websocket connections and parse potential queries from
@@ -82,7 +191,8 @@ func verifySato(wg *sync.WaitGroup, p bool, suite abstract.Suite, A, B []abstrac
 func (server *Server) connection(w http.ResponseWriter, r *http.Request) {
 	ws, err := server.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		panic("Upgrading TCP to WS failed")
+		log.Printf("Upgrading TCP to WS failed: %v", err)
+		return
 	}
 	defer ws.Close()
 
@@ -103,41 +213,112 @@ func (server *Server) distribute() {
 	for {
 		msg := <-server.broadcast
 
-		suite := nist.NewAES128SHA256P256()
-		stream := suite.Cipher(abstract.RandomKey)
+		name := msg.Suite
+		if name == "" {
+			name = server.suite
+		}
+		res := response{Algorithm: msg.Algorithm, Suite: name, Votes: len(msg.Votes)}
+
+		suite, bases, err := server.key(name)
+		if err != nil {
+			res.finish(mixnet.Timings{}, 0, err)
+			server.send(res)
+			continue
+		}
+		stream := suite.RandomStream()
+
+		A, B, err := encrypt(suite, bases, msg.Votes, stream)
+		if err != nil {
+			res.finish(mixnet.Timings{}, 0, err)
+			server.send(res)
+			continue
+		}
+		res.Width = len(A)
 
-		A, B := encrypt(suite, msg.Votes, stream)
+		// Proofs are bound to the session of the query or a fresh one.
+		session := []byte(msg.Session)
+		if len(session) == 0 {
+			session = random.Bits(128, false, stream)
+		}
 
-		var wg sync.WaitGroup
-		start := time.Now()
 		if msg.Algorithm == "neff" {
-			verifyNeff(suite, A, B, stream)
+			verifyNeff(suite, bases, A, B, session, msg.Mixers, msg.Parallelize, stream, &res)
 		} else {
-			for i := 0; i < 80; i++ {
-				if msg.Parallelize {
-					wg.Add(1)
-					go verifySato(&wg, true, suite, A, B, stream)
-				} else {
-					verifySato(&wg, false, suite, A, B, stream)
-				}
-			}
+			verifySato(suite, bases.H, A, B, session, msg.Parallelize, stream, &res)
 		}
-		wg.Wait()
-		elapsed := time.Since(start)
-
-		for client := range server.clients {
-			if client.WriteJSON(elapsed.String()) != nil {
-				client.Close()
-				delete(server.clients, client)
-			}
+
+		server.send(res)
+	}
+}
+
+// Send a response to all connected clients.
+func (server *Server) send(res response) {
+	for client := range server.clients {
+		if client.WriteJSON(res) != nil {
+			client.Close()
+			delete(server.clients, client)
 		}
 	}
 }
 
+// Look up the suite with the given name and the fixed-base tables of its
+// election key, which are built on first use. Suites other than the one of
+// the key file get an ephemeral key.
+func (server *Server) key(name string) (suites.Suite, *elgamal.Bases, error) {
+	suite, err := suites.Lookup(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if bases, ok := server.bases[name]; ok {
+		return suite, bases, nil
+	}
+
+	key, ok := server.keys[name]
+	if !ok {
+		key = elgamal.GenerateKey(suite, suite.RandomStream())
+		server.keys[name] = key
+	}
+	bases := elgamal.NewBases(suite, suite.Point().Base(), key.Public)
+	server.bases[name] = bases
+
+	return suite, bases, nil
+}
+
+// Load the election key from path or generate a fresh one if path is empty.
+func loadKey(suite suites.Suite, path string) (*elgamal.KeyPair, error) {
+	if path == "" {
+		return elgamal.GenerateKey(suite, suite.RandomStream()), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return elgamal.ReadKey(suite, f)
+}
+
 // Creation of HTTP server and its respective websockets with listening
-// at the provided root directory.
-func Open(root string) *Server {
+// at the provided root directory. Queries without a suite are run in the
+// named default suite whose ballots are encrypted under the election key
+// stored at keyPath, an ephemeral key is used if it is empty.
+func Open(root, suiteName, keyPath string) *Server {
 	server := new(Server)
+	server.suite = suiteName
+
+	suite, err := suites.Lookup(suiteName)
+	if err != nil {
+		panic(err)
+	}
+	key, err := loadKey(suite, keyPath)
+	if err != nil {
+		panic(err)
+	}
+	server.keys = map[string]*elgamal.KeyPair{suiteName: key}
+	server.bases = make(map[string]*elgamal.Bases)
+
 	server.root = http.FileServer(http.Dir(root))
 	server.clients = make(map[*websocket.Conn]bool)
 	server.broadcast = make(chan query)
@@ -13,19 +13,31 @@ window.onload = () => {
     let neff = document.getElementById('neff')
     let sato = document.getElementById('sato')
     let parallel = document.getElementById('parallel')
+    let mixers = document.getElementById('mixers')
+    let suite = document.getElementById('suite')
 
     const socket = new WebSocket('ws://localhost:8000/ws')
 
     socket.onmessage = (event) => {
+        let res = JSON.parse(event.data)
+        let text = res.suite + ': ' + res.time + ' (shuffle ' + res.shuffle + ', prove ' + res.prove
+        if (res.simple) {
+            text += ' of which simple shuffle ' + res.simple
+        }
+        text += ', verify ' + res.verify + ')'
+        text += res.verified ? ' verified' : ' FAILED: ' + res.error
+
         time.innerHTML = ''
-        time.append(event.data)
+        time.append(text)
     }
 
     document.getElementById('button').addEventListener('click', () => {
         let query = {
             votes: generateVotes(field.value),
             algorithm: neff.checked ? 'neff' : 'sato',
-            parallelize: parallel.checked ? true : false
+            parallelize: parallel.checked ? true : false,
+            mixers: parseInt(mixers.value),
+            suite: suite.value
         }
 
         socket.send(JSON.stringify(query))